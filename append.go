@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// AppendToArray appends item to a record holding a JSON array, creating the
+// record as a one-element array if it doesn't exist. A record that is an
+// object with exactly one array-valued field has item appended to that
+// field instead. The read and write happen under the collection lock.
+func (d *Driver) AppendToArray(collection, resource string, item interface{}) error {
+	if collection == "" {
+		return fmt.Errorf("%w - unable to append", ErrMissingCollection)
+	}
+	if resource == "" {
+		return fmt.Errorf("%w - unable to append (no name)", ErrMissingResource)
+	}
+	if err := validNames(collection, resource); err != nil {
+		return err
+	}
+
+	value, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+
+	mutex := d.getOrCreateMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	b, err := d.read(collection, resource)
+	if errors.Is(err, ErrNotFound) {
+		b, err := d.marshal(collection, resource, []json.RawMessage{value})
+		if err != nil {
+			return err
+		}
+		return d.put(collection, resource, b)
+	}
+	if err != nil {
+		return err
+	}
+
+	raw, err := appendRecord(b, value)
+	if err != nil {
+		return fmt.Errorf("unable to append to %v/%v: %w", collection, resource, err)
+	}
+	if b, err = d.marshal(collection, resource, raw); err != nil {
+		return err
+	}
+	return d.write(collection, resource, b)
+}
+
+// appendRecord appends value to the array record b or to the only array
+// field of the object record b.
+func appendRecord(b, value []byte) (json.RawMessage, error) {
+	b = bytes.TrimSpace(b)
+	if len(b) > 0 && b[0] == '[' {
+		return appendRaw(b, value)
+	}
+
+	obj, err := decodeObject(b)
+	if err != nil {
+		return nil, fmt.Errorf("record is neither an array nor an object")
+	}
+
+	field := -1
+	for i, f := range obj {
+		if v := bytes.TrimSpace(f.Value); len(v) == 0 || v[0] != '[' {
+			continue
+		}
+		if field >= 0 {
+			return nil, fmt.Errorf("record has more than one array field (%v, %v)", obj[field].Key, f.Key)
+		}
+		field = i
+	}
+	if field < 0 {
+		return nil, fmt.Errorf("record is not an array and has no array field")
+	}
+
+	if obj[field].Value, err = appendRaw(obj[field].Value, value); err != nil {
+		return nil, err
+	}
+	return obj.raw(), nil
+}
+
+// appendRaw appends value to the JSON array arr.
+func appendRaw(arr, value []byte) (json.RawMessage, error) {
+	var items []json.RawMessage
+	if err := json.Unmarshal(arr, &items); err != nil {
+		return nil, err
+	}
+	return json.Marshal(append(items, value))
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ArchiveCollection writes collection to w as a gzip-compressed tar stream
+// of the files in its directory, including its indexes and metadata. Files
+// are streamed one at a time under the collection read lock, so memory use
+// doesn't grow with the collection; in-progress .tmp files are skipped.
+func (d *Driver) ArchiveCollection(collection string, w io.Writer) error {
+	if collection == "" {
+		return fmt.Errorf("%w - unable to archive", ErrMissingCollection)
+	}
+	if err := validNames(collection); err != nil {
+		return err
+	}
+	if d.opts.SingleFilePerCollection {
+		return fmt.Errorf("ArchiveCollection is not supported with SingleFilePerCollection")
+	}
+
+	if err := d.begin(); err != nil {
+		return err
+	}
+	defer d.end()
+
+	mutex := d.getOrCreateMutex(collection)
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	src, err := filepath.EvalSymlinks(d.collectionDir(collection))
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(w)
+	tw := tar.NewWriter(zw)
+	err = filepath.WalkDir(src, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() || strings.HasSuffix(entry.Name(), ".tmp") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		return archiveFile(tw, path, filepath.ToSlash(rel))
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func archiveFile(tw *tar.Writer, path, name string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	hdr, err := tar.FileInfoHeader(fi, "")
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// UnarchiveCollection restores an archive written by ArchiveCollection into
+// collection, which need not have the name it was archived under. Each file
+// is replaced atomically, but files already in the collection and not in
+// the archive are kept, so restore into a new or empty collection.
+func (d *Driver) UnarchiveCollection(collection string, r io.Reader) error {
+	if collection == "" {
+		return fmt.Errorf("%w - unable to restore", ErrMissingCollection)
+	}
+	if err := validNames(collection); err != nil {
+		return err
+	}
+	if d.opts.ReadOnly {
+		return ErrReadOnly
+	}
+	if d.opts.SingleFilePerCollection {
+		return fmt.Errorf("UnarchiveCollection is not supported with SingleFilePerCollection")
+	}
+
+	if err := d.begin(); err != nil {
+		return err
+	}
+	defer d.end()
+
+	mutex := d.getOrCreateMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	dir := d.collectionDir(collection)
+	tr := tar.NewReader(zr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name := filepath.FromSlash(hdr.Name)
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("invalid archive entry %v", hdr.Name)
+		}
+
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), d.dirMode); err != nil {
+			return err
+		}
+		if err := d.writeStream(path, tr); err != nil {
+			return err
+		}
+	}
+
+	return d.reloadMeta(collection)
+}
+
+// reloadMeta reads the metadata of collection back from disk. It must be
+// called with the collection lock held.
+func (d *Driver) reloadMeta(collection string) error {
+	b, err := os.ReadFile(filepath.Join(d.collectionDir(collection), metaFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var meta collectionMeta
+	if err := json.Unmarshal(b, &meta); err != nil {
+		return fmt.Errorf("invalid metadata for collection %v: %v", collection, err)
+	}
+
+	d.mutex.Lock()
+	d.metas[d.fold(collection)] = meta
+	d.mutex.Unlock()
+	return nil
+}
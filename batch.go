@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Entry is a record to store with WriteMany.
+type Entry struct {
+	Resource string
+	Value    interface{}
+}
+
+// WriteMany writes entries to collection in the order given, under a single
+// hold of the collection lock. It stops at the first failure and reports
+// which resource failed; the entries before it remain written.
+func (d *Driver) WriteMany(collection string, entries []Entry) error {
+	if collection == "" {
+		return fmt.Errorf("%w - no place to save records", ErrMissingCollection)
+	}
+	for _, e := range entries {
+		if e.Resource == "" {
+			return fmt.Errorf("%w - unable to save record (no name)!", ErrMissingResource)
+		}
+		if err := validNames(collection, e.Resource); err != nil {
+			return err
+		}
+	}
+
+	records := make([][]byte, len(entries))
+	for i, e := range entries {
+		b, err := d.marshal(collection, e.Resource, e.Value)
+		if err != nil {
+			return fmt.Errorf("batch write failed at %v (%d of %d): %w", e.Resource, i+1, len(entries), err)
+		}
+		records[i] = b
+	}
+
+	mutex := d.getOrCreateMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	for i, e := range entries {
+		d.log.Debug("Batch write %d/%d: %s/%s", i+1, len(entries), collection, e.Resource)
+		if err := d.put(collection, e.Resource, records[i]); err != nil {
+			return fmt.Errorf("batch write failed at %v (%d of %d): %w", e.Resource, i+1, len(entries), err)
+		}
+	}
+	return nil
+}
+
+// WriteMap is WriteMany for a map, writing its entries in sorted key order
+// so that runs are reproducible.
+func (d *Driver) WriteMap(collection string, records map[string]interface{}) error {
+	keys := make([]string, 0, len(records))
+	for k := range records {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	entries := make([]Entry, len(keys))
+	for i, k := range keys {
+		entries[i] = Entry{k, records[k]}
+	}
+	return d.WriteMany(collection, entries)
+}
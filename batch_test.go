@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriteMapOrder(t *testing.T) {
+	records := map[string]interface{}{}
+	for i := 0; i < 20; i++ {
+		records[fmt.Sprintf("k%02d", i)] = i
+	}
+
+	var runs [][]string
+	for run := 0; run < 3; run++ {
+		log := &recordingLogger{match: "Batch write"}
+		d := newTestDriver(t, &Options{Logger: log, WritePolicy: FailIfExists})
+		for _, name := range []string{"k12", "k05"} {
+			if err := d.Write("users", name, 0); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// k05 is the first existing key in sorted order, whatever order
+		// the map iterates in.
+		err := d.WriteMap("users", records)
+		if !errors.Is(err, ErrAlreadyExists) || !strings.Contains(err.Error(), "k05 (6 of 20)") {
+			t.Fatalf("WriteMap = %v, want failure at k05", err)
+		}
+		runs = append(runs, log.lines)
+	}
+
+	want := []string{"Batch write 1/20: users/k00"}
+	for i := 2; i <= 6; i++ {
+		want = append(want, fmt.Sprintf("Batch write %d/20: users/k%02d", i, i-1))
+	}
+	for _, lines := range runs {
+		if !reflect.DeepEqual(lines, want) {
+			t.Fatalf("logged %q, want %q", lines, want)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"testing"
+)
+
+var (
+	benchRecordSizes     = []int{100, 10 << 10, 1 << 20}
+	benchCollectionSizes = []int{10, 1000}
+	benchBufferSizes     = []int{4 << 10, defaultBufferSize, 1 << 20}
+)
+
+// benchRecord returns a record whose JSON encoding is about size bytes.
+func benchRecord(size int) map[string]string {
+	return map[string]string{"data": string(bytes.Repeat([]byte("x"), size))}
+}
+
+func BenchmarkWrite(b *testing.B) {
+	for _, size := range benchRecordSizes {
+		b.Run(fmt.Sprintf("record=%d", size), func(b *testing.B) {
+			d := newTestDriver(b, nil)
+			v := benchRecord(size)
+			b.SetBytes(int64(size))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := d.Write("bench", "r"+strconv.Itoa(i%100), v); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkRead(b *testing.B) {
+	for _, size := range benchRecordSizes {
+		b.Run(fmt.Sprintf("record=%d", size), func(b *testing.B) {
+			d := newTestDriver(b, nil)
+			if err := d.Write("bench", "r", benchRecord(size)); err != nil {
+				b.Fatal(err)
+			}
+			b.SetBytes(int64(size))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				var v map[string]string
+				if err := d.Read("bench", "r", &v); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkReadAll(b *testing.B) {
+	for _, count := range benchCollectionSizes {
+		// A thousand 1MB records would only measure the disk.
+		for _, size := range benchRecordSizes[:2] {
+			b.Run(fmt.Sprintf("records=%d/record=%d", count, size), func(b *testing.B) {
+				d := newTestDriver(b, nil)
+				v := benchRecord(size)
+				for i := 0; i < count; i++ {
+					if err := d.Write("bench", "r"+strconv.Itoa(i), v); err != nil {
+						b.Fatal(err)
+					}
+				}
+				b.SetBytes(int64(count * size))
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if _, err := d.ReadAll("bench"); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkBlob streams a large blob in and out at each buffer size.
+func BenchmarkBlob(b *testing.B) {
+	data := bytes.Repeat([]byte("x"), 8<<20)
+	for _, size := range benchBufferSizes {
+		b.Run(fmt.Sprintf("buffer=%d", size), func(b *testing.B) {
+			d := newTestDriver(b, &Options{ReadBufferSize: size, WriteBufferSize: size})
+			b.SetBytes(int64(len(data)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := d.WriteBlob("bench", "blob", bytes.NewReader(data)); err != nil {
+					b.Fatal(err)
+				}
+				r, err := d.ReadBlob("bench", "blob")
+				if err != nil {
+					b.Fatal(err)
+				}
+				_, err = io.Copy(io.Discard, r)
+				r.Close()
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
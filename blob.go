@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const blobExt = ".blob"
+
+// WriteBlob stores the bytes read from r as-is under collection/resource.
+// Blobs live next to JSON records with a .blob extension and are not
+// returned by ReadAll or List.
+func (d *Driver) WriteBlob(collection, resource string, r io.Reader) error {
+	if collection == "" {
+		return fmt.Errorf("%w - no place to save blob", ErrMissingCollection)
+	}
+	if resource == "" {
+		return fmt.Errorf("%w - unable to save blob (no name)!", ErrMissingResource)
+	}
+	if err := validNames(collection, resource); err != nil {
+		return err
+	}
+	if d.opts.ReadOnly {
+		return ErrReadOnly
+	}
+
+	if err := d.begin(); err != nil {
+		return err
+	}
+	defer d.end()
+
+	mutex := d.getOrCreateMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	finalPath := d.recordPath(collection, resource) + blobExt
+	if err := os.MkdirAll(filepath.Dir(finalPath), d.dirMode); err != nil {
+		d.log.Error("Failed to create directory: %v", err)
+		return err
+	}
+	if err := d.checkSpace(filepath.Dir(finalPath), 0); err != nil {
+		return err
+	}
+
+	d.log.Debug("Writing blob: %s", finalPath)
+	return d.writeStream(finalPath, r)
+}
+
+// ReadBlob opens the blob stored under collection/resource, buffered with
+// Options.ReadBufferSize. The caller must close the returned reader.
+func (d *Driver) ReadBlob(collection, resource string) (io.ReadCloser, error) {
+	if collection == "" {
+		return nil, fmt.Errorf("%w - unable to read blob", ErrMissingCollection)
+	}
+	if resource == "" {
+		return nil, fmt.Errorf("%w - unable to read blob (no name)", ErrMissingResource)
+	}
+	if err := validNames(collection, resource); err != nil {
+		return nil, err
+	}
+
+	if err := d.begin(); err != nil {
+		return nil, err
+	}
+	defer d.end()
+
+	f, err := os.Open(d.recordPath(collection, resource) + blobExt)
+	if err != nil {
+		return nil, err
+	}
+	return bufferedFile{bufio.NewReaderSize(f, d.opts.ReadBufferSize), f}, nil
+}
+
+type bufferedFile struct {
+	*bufio.Reader
+	io.Closer
+}
+
+// ListBlobs returns the names of the blobs in collection, in sorted order.
+func (d *Driver) ListBlobs(collection string) ([]string, error) {
+	if collection == "" {
+		return nil, fmt.Errorf("%w - unable to list", ErrMissingCollection)
+	}
+	if err := validNames(collection); err != nil {
+		return nil, err
+	}
+
+	if err := d.begin(); err != nil {
+		return nil, err
+	}
+	defer d.end()
+
+	mutex := d.getOrCreateMutex(collection)
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	files, err := d.recordEntries(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, file := range files {
+		if file.IsDir() || !isBlob(file.Name()) {
+			continue
+		}
+		names = append(names, strings.TrimSuffix(file.Name(), blobExt))
+	}
+	return names, nil
+}
+
+func isBlob(name string) bool {
+	return strings.HasSuffix(name, blobExt) && !strings.HasPrefix(name, ".")
+}
+
+// writeStream is the streaming counterpart of writeFile. It copies through
+// a buffer of Options.WriteBufferSize.
+func (d *Driver) writeStream(path string, r io.Reader) error {
+	tmpPath := path + ".tmp"
+	f, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, d.fileMode)
+	if err != nil {
+		return err
+	}
+
+	// Hide f's ReadFrom so that the copy goes through the buffer.
+	buf := make([]byte, d.opts.WriteBufferSize)
+	if _, err := io.CopyBuffer(struct{ io.Writer }{f}, r, buf); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// canonicalize re-encodes the JSON value b with the keys of every object in
+// sorted order, keeping numbers exactly as written, so that equal records
+// always produce identical bytes.
+func canonicalize(b []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
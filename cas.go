@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ReadForUpdate is Read that also returns an opaque token identifying the
+// current contents of the record, for a later WriteIfUnchanged.
+func (d *Driver) ReadForUpdate(collection, resource string, v interface{}) (token string, err error) {
+	if collection == "" {
+		return "", fmt.Errorf("%w - unable to read", ErrMissingCollection)
+	}
+	if resource == "" {
+		return "", fmt.Errorf("%w - unable to read (no name)", ErrMissingResource)
+	}
+	if err := validNames(collection, resource); err != nil {
+		return "", err
+	}
+	if err := checkTarget(v); err != nil {
+		return "", err
+	}
+
+	var b []byte
+	err = d.withTimeout(func(ctx context.Context) error {
+		if err := d.limit(ctx, collection); err != nil {
+			return err
+		}
+
+		var err error
+		b, err = d.readThrough(collection, resource)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	if err := d.decode(collection, resource, b, v); err != nil {
+		return "", err
+	}
+	return string(checksum(b)), nil
+}
+
+// WriteIfUnchanged writes v to collection/resource only if the record still
+// matches token, as returned by ReadForUpdate, and fails with ErrConflict
+// otherwise, including when the record has been deleted since. The check
+// and the write happen under the collection lock, so this is a
+// compare-and-swap without a version field in the record.
+func (d *Driver) WriteIfUnchanged(collection, resource string, v interface{}, token string) error {
+	if collection == "" {
+		return fmt.Errorf("%w - no place to save records", ErrMissingCollection)
+	}
+	if resource == "" {
+		return fmt.Errorf("%w - unable to save record (no name)!", ErrMissingResource)
+	}
+	if err := validNames(collection, resource); err != nil {
+		return err
+	}
+
+	b, err := d.marshal(collection, resource, v)
+	if err != nil {
+		d.log.Error("JSON Marshalling failed: %v", err)
+		return err
+	}
+
+	return d.withTimeout(func(ctx context.Context) error {
+		mutex := d.getOrCreateMutex(collection)
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		current, err := d.readLocked(collection, resource)
+		if errors.Is(err, ErrNotFound) {
+			return ErrConflict
+		}
+		if err != nil {
+			return err
+		}
+		if string(checksum(current)) != token {
+			return ErrConflict
+		}
+		return d.put(collection, resource, b)
+	})
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+// noDeadlock fails the test if fn doesn't return within a few seconds, which
+// here means it deadlocked on the collection lock.
+func noDeadlock(t *testing.T, fn func() error) error {
+	t.Helper()
+	errc := make(chan error, 1)
+	go func() { errc <- fn() }()
+	select {
+	case err := <-errc:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("deadlocked")
+		return nil
+	}
+}
+
+func TestWriteIfUnchanged(t *testing.T) {
+	d := newTestDriver(t, nil)
+	if err := d.Write("users", "john", map[string]int{"age": 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	var v map[string]int
+	token, err := d.ReadForUpdate("users", "john", &v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.WriteIfUnchanged("users", "john", map[string]int{"age": 2}, token); err != nil {
+		t.Fatalf("first write: %v", err)
+	}
+	if err := d.WriteIfUnchanged("users", "john", map[string]int{"age": 3}, token); !errors.Is(err, ErrConflict) {
+		t.Fatalf("stale token: got %v, want ErrConflict", err)
+	}
+	if err := d.Read("users", "john", &v); err != nil || v["age"] != 2 {
+		t.Fatalf("got %v, %v, want age 2", v, err)
+	}
+}
+
+func TestWriteIfUnchangedFromFallback(t *testing.T) {
+	fallback := newTestDriver(t, nil)
+	if err := fallback.Write("users", "john", map[string]int{"age": 1}); err != nil {
+		t.Fatal(err)
+	}
+	d := newTestDriver(t, &Options{Fallback: fallback, PopulateFromFallback: true})
+
+	var v map[string]int
+	token, err := d.ReadForUpdate("users", "john", &v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Delete("users", "john"); err != nil {
+		t.Fatal(err)
+	}
+
+	// john is now only in the fallback again, so the check reads it from
+	// there with the lock held.
+	err = noDeadlock(t, func() error {
+		return d.WriteIfUnchanged("users", "john", map[string]int{"age": 2}, token)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadRawUnderLockCollection(t *testing.T) {
+	fallback := newTestDriver(t, nil)
+	if err := fallback.Write("users", "jane", map[string]int{"age": 1}); err != nil {
+		t.Fatal(err)
+	}
+	d := newTestDriver(t, &Options{Fallback: fallback, PopulateFromFallback: true, StaleTmp: PromoteStaleTmp})
+	if err := d.Write("users", "john", map[string]int{"age": 1}); err != nil {
+		t.Fatal(err)
+	}
+	path := d.recordPath("users", "john") + ".json"
+	if err := os.WriteFile(path+".tmp", []byte(`{"age":2}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path+".tmp", later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	unlock := d.LockCollection("users")
+	defer unlock()
+	for _, name := range []string{"john", "jane"} {
+		err := noDeadlock(t, func() error {
+			_, err := d.ReadRaw("users", name)
+			return err
+		})
+		if err != nil {
+			t.Errorf("ReadRaw %v: %v", name, err)
+		}
+	}
+}
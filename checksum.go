@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// With Options.Checksum every write also stores the SHA-256 of the record in
+// the collection's .checksums directory, and reads compare against it. The
+// checksum is written after the record, so a crash between the two makes
+// the record fail verification until it is written again. Records without
+// a checksum, e.g. written before the option was enabled, are not checked.
+
+// verifyChecksum compares b, just read from a record, with its stored
+// checksum.
+func (d *Driver) verifyChecksum(collection, resource string, b []byte) error {
+	want, err := os.ReadFile(d.checksumPath(collection, resource))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(bytes.TrimSpace(want), checksum(b)) {
+		return nil
+	}
+	return fmt.Errorf("%w: %v/%v", ErrChecksumMismatch, collection, resource)
+}
+
+// saveChecksum must be called with the collection lock held.
+func (d *Driver) saveChecksum(collection, resource string, b []byte) error {
+	path := d.checksumPath(collection, resource)
+	if err := os.MkdirAll(filepath.Dir(path), d.dirMode); err != nil {
+		return err
+	}
+	return d.writeFile(path, append(checksum(b), '\n'))
+}
+
+// removeChecksum must be called with the collection lock held.
+func (d *Driver) removeChecksum(collection, resource string) error {
+	err := os.Remove(d.checksumPath(collection, resource))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (d *Driver) checksumPath(collection, resource string) string {
+	return filepath.Join(d.collectionDir(collection), ".checksums", d.fold(resource)+".sha256")
+}
+
+func checksum(b []byte) []byte {
+	sum := sha256.Sum256(b)
+	return []byte(hex.EncodeToString(sum[:]))
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CloneTo copies the whole database to destDir and returns a Driver opened
+// on the copy with the same options, except that ReplicaDirs, Fallback and
+// WriteThrough are cleared: those point at other stores, and writes to the
+// copy must not reach them. AutoCompactInterval is kept, since the
+// compactor only works on the clone's own directory and stops with its
+// Close. Each collection is copied under its read lock; in-progress .tmp
+// files are skipped.
+func (d *Driver) CloneTo(destDir string) (*Driver, error) {
+	src, err := realPath(d.dir)
+	if err != nil {
+		return nil, err
+	}
+	dst, err := resolvePath(destDir)
+	if err != nil {
+		return nil, err
+	}
+	if within(src, dst) {
+		return nil, fmt.Errorf("unable to clone %v into itself (%v)", src, dst)
+	}
+
+	if err := d.begin(); err != nil {
+		return nil, err
+	}
+	defer d.end()
+
+	if err := os.MkdirAll(dst, d.dirMode); err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if !isDir(src, entry) {
+			if err := d.copyFile(filepath.Join(src, entry.Name()), filepath.Join(dst, entry.Name())); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		d.log.Debug("Cloning collection '%s' to %s", entry.Name(), dst)
+		mutex := d.getOrCreateMutex(entry.Name())
+		mutex.RLock()
+		err := d.copyTree(filepath.Join(src, entry.Name()), filepath.Join(dst, entry.Name()))
+		mutex.RUnlock()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	opts := d.opts
+	opts.ReplicaDirs = nil
+	opts.Fallback = nil
+	opts.PopulateFromFallback = false
+	opts.WriteThrough = false
+	return New(dst, &opts)
+}
+
+// resolvePath is realPath for a path that may not exist yet: it resolves
+// the longest existing prefix and appends the rest.
+func resolvePath(path string) (string, error) {
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	rest := ""
+	for {
+		real, err := filepath.EvalSymlinks(dir)
+		if err == nil {
+			return filepath.Join(real, rest), nil
+		}
+		parent := filepath.Dir(dir)
+		if !os.IsNotExist(err) || parent == dir {
+			return "", err
+		}
+		rest = filepath.Join(filepath.Base(dir), rest)
+		dir = parent
+	}
+}
+
+// copyTree copies the directory src, following it if it is a symlink, but
+// not symlinks inside it.
+func (d *Driver) copyTree(src, dst string) error {
+	src, err := filepath.EvalSymlinks(src)
+	if err != nil {
+		return err
+	}
+	return filepath.WalkDir(src, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		if entry.IsDir() {
+			return os.MkdirAll(target, d.dirMode)
+		}
+		if strings.HasSuffix(entry.Name(), ".tmp") {
+			return nil
+		}
+		return d.copyFile(path, target)
+	})
+}
+
+func (d *Driver) copyFile(src, dst string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return d.writeStream(dst, f)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCloneToDetachesOtherStores(t *testing.T) {
+	fallback := newTestDriver(t, nil)
+	replica := t.TempDir()
+	d := newTestDriver(t, &Options{ReplicaDirs: []string{replica}, Fallback: fallback, WriteThrough: true})
+	if err := d.Write("users", "john", map[string]int{"age": 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	clone, err := d.CloneTo(filepath.Join(t.TempDir(), "clone"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clone.Close()
+
+	var v map[string]int
+	if err := clone.Read("users", "john", &v); err != nil || v["age"] != 1 {
+		t.Fatalf("clone Read = %v, %v", v, err)
+	}
+	if err := clone.Write("users", "jane", map[string]int{"age": 2}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(replica, "users", "jane.json")); !os.IsNotExist(err) {
+		t.Errorf("clone wrote to the primary's replica: %v", err)
+	}
+	if _, err := fallback.ReadRaw("users", "jane"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("clone wrote through to the primary's fallback: %v", err)
+	}
+}
+
+func TestCloneToRefusesItself(t *testing.T) {
+	d := newTestDriver(t, nil)
+	if err := d.Write("users", "john", map[string]int{"age": 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	link := filepath.Join(t.TempDir(), "link")
+	if err := os.Symlink(d.dir, link); err != nil {
+		t.Fatal(err)
+	}
+	for _, dest := range []string{d.dir, filepath.Join(d.dir, "sub"), filepath.Join(link, "sub", "dir")} {
+		if clone, err := d.CloneTo(dest); err == nil {
+			clone.Close()
+			t.Errorf("CloneTo(%v) succeeded", dest)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(d.dir, "sub")); !os.IsNotExist(err) {
+		t.Errorf("refused clone left a directory behind: %v", err)
+	}
+
+	// A sibling whose name merely starts with ".." is still inside.
+	if clone, err := d.CloneTo(filepath.Join(d.dir, "..foo")); err == nil {
+		clone.Close()
+		t.Error("CloneTo(..foo) succeeded")
+	}
+
+	clone, err := d.CloneTo(d.dir + "-copy")
+	if err != nil {
+		t.Fatalf("CloneTo sibling: %v", err)
+	}
+	clone.Close()
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// CreateCollection creates an empty collection, with its directory and
+// metadata, and fails with ErrAlreadyExists if it exists. Collections are
+// otherwise created by their first write, unless
+// Options.RequireExistingCollection is set.
+func (d *Driver) CreateCollection(collection string) error {
+	if collection == "" {
+		return fmt.Errorf("%w - unable to create", ErrMissingCollection)
+	}
+	if err := validNames(collection); err != nil {
+		return err
+	}
+	if d.opts.ReadOnly {
+		return ErrReadOnly
+	}
+
+	if err := d.begin(); err != nil {
+		return err
+	}
+	defer d.end()
+
+	mutex := d.getOrCreateMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	unlock, err := d.lockFile(collection)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if d.collectionExists(collection) {
+		return fmt.Errorf("collection %v: %w", collection, ErrAlreadyExists)
+	}
+
+	d.log.Debug("Creating collection '%s'", collection)
+	if d.opts.SingleFilePerCollection {
+		return d.saveSingle(collection, map[string]json.RawMessage{})
+	}
+	return d.saveMeta(collection, d.meta(collection))
+}
+
+// AllRecordPaths returns the absolute paths of the files of every record in
+// the database, collection by collection in name order. Temp files, blobs
+// and metadata are left out. With SingleFilePerCollection it returns the
+// collection files instead, which hold all the records.
+func (d *Driver) AllRecordPaths() ([]string, error) {
+	root, err := filepath.Abs(d.dir)
+	if err != nil {
+		return nil, err
+	}
+	collections, err := d.Collections()
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, collection := range collections {
+		if d.opts.SingleFilePerCollection {
+			paths = append(paths, filepath.Join(root, collection+".json"))
+			continue
+		}
+
+		mutex := d.getOrCreateMutex(collection)
+		mutex.RLock()
+		names, err := d.list(collection)
+		mutex.RUnlock()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, name := range names {
+			rel, err := filepath.Rel(d.dir, d.recordPath(collection, name)+".json")
+			if err != nil {
+				return nil, err
+			}
+			paths = append(paths, filepath.Join(root, rel))
+		}
+	}
+	return paths, nil
+}
+
+// DeleteAll deletes every collection and its records, leaving the database
+// directory itself in place. It holds the locks of all the collections, so
+// it waits for operations in progress and runs as one step with respect to
+// other callers. A collection that fails to delete doesn't stop the others;
+// the errors are joined.
+func (d *Driver) DeleteAll() error {
+	if d.opts.ReadOnly {
+		return ErrReadOnly
+	}
+
+	collections, err := d.Collections()
+	if err != nil {
+		return err
+	}
+
+	// Lock in name order, like relocate, so that this can't deadlock with
+	// other multi-collection operations.
+	sort.Slice(collections, func(i, j int) bool { return d.fold(collections[i]) < d.fold(collections[j]) })
+	for _, collection := range collections {
+		mutex := d.getOrCreateMutex(collection)
+		mutex.Lock()
+		defer mutex.Unlock()
+	}
+
+	var errs []error
+	for _, collection := range collections {
+		d.log.Debug("Deleting collection '%s'", collection)
+		if err := d.delete(collection, ""); err != nil {
+			errs = append(errs, fmt.Errorf("collection %v: %w", collection, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// SeedIfEmpty writes records, keyed by resource name, if collection has no
+// records or doesn't exist, and reports whether it did. The check and the
+// writes happen under the collection lock, so concurrent callers seed it at
+// most once. Records are written in name order; on error the ones before
+// have already been written.
+func (d *Driver) SeedIfEmpty(collection string, records map[string]interface{}) (bool, error) {
+	if collection == "" {
+		return false, fmt.Errorf("%w - no place to save records", ErrMissingCollection)
+	}
+
+	names := make([]string, 0, len(records))
+	for name := range records {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(names) > 0 && names[0] == "" {
+		return false, fmt.Errorf("%w - unable to save record (no name)!", ErrMissingResource)
+	}
+	if err := validNames(append(names, collection)...); err != nil {
+		return false, err
+	}
+
+	mutex := d.getOrCreateMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	existing, err := d.list(collection)
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	if len(existing) > 0 {
+		return false, nil
+	}
+
+	for _, name := range names {
+		b, err := d.marshal(collection, name, records[name])
+		if err != nil {
+			d.log.Error("JSON Marshalling failed: %v", err)
+			return false, err
+		}
+		if err := d.put(collection, name, b); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestDeleteShardedCollection(t *testing.T) {
+	d := newTestDriver(t, &Options{Sharding: 1})
+	for _, c := range []string{"users", "posts"} {
+		for _, r := range []string{"john", "jane"} {
+			if err := d.Write(c, r, map[string]string{"name": r}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if err := d.Delete("users", ""); err != nil {
+		t.Fatalf("Delete collection: %v", err)
+	}
+	if _, err := os.Stat(d.collectionDir("users")); !os.IsNotExist(err) {
+		t.Fatalf("users still exists: %v", err)
+	}
+
+	if err := d.DeleteAll(); err != nil {
+		t.Fatalf("DeleteAll: %v", err)
+	}
+	if collections, err := d.Collections(); err != nil || len(collections) != 0 {
+		t.Fatalf("Collections = %v, %v, want none", collections, err)
+	}
+}
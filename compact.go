@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// CompactRecord rewrites a record without insignificant whitespace, e.g. one
+// that was pretty-printed before the collection was configured as Compact.
+func (d *Driver) CompactRecord(collection, resource string) error {
+	if collection == "" {
+		return fmt.Errorf("%w - unable to compact", ErrMissingCollection)
+	}
+	if resource == "" {
+		return fmt.Errorf("%w - unable to compact (no name)", ErrMissingResource)
+	}
+	if err := validNames(collection, resource); err != nil {
+		return err
+	}
+
+	mutex := d.getOrCreateMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	_, err := d.compact(collection, resource)
+	return err
+}
+
+// CompactCollection applies CompactRecord to every record in collection.
+func (d *Driver) CompactCollection(collection string) error {
+	if collection == "" {
+		return fmt.Errorf("%w - unable to compact", ErrMissingCollection)
+	}
+	if err := validNames(collection); err != nil {
+		return err
+	}
+
+	mutex := d.getOrCreateMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	names, err := d.list(collection)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		if _, err := d.compact(collection, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compact rewrites a single record compactly and returns the number of
+// bytes saved. It must be called with the collection lock held.
+func (d *Driver) compact(collection, resource string) (int, error) {
+	b, err := d.read(collection, resource)
+	if err != nil {
+		return 0, err
+	}
+
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, b); err != nil {
+		return 0, fmt.Errorf("unable to compact %v: %v", resource, err)
+	}
+	if !d.config(collection).OmitNewline {
+		buf.WriteByte('\n')
+	}
+
+	if buf.Len() == len(b) {
+		return 0, nil
+	}
+	if err := d.write(collection, resource, buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(b) - buf.Len(), nil
+}
+
+func (d *Driver) autoCompact(interval time.Duration) {
+	defer close(d.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.stop:
+			return
+		case <-ticker.C:
+			d.compactAll()
+		}
+	}
+}
+
+// compactAll vacuums every collection in turn, releasing each collection's
+// lock before moving on to the next so foreground operations can proceed.
+func (d *Driver) compactAll() {
+	collections, err := d.Collections()
+	if err != nil {
+		d.log.Error("Auto-compaction failed to list collections: %v", err)
+		return
+	}
+
+	var total int64
+	for _, collection := range collections {
+		select {
+		case <-d.stop:
+			return
+		default:
+		}
+
+		n, err := d.vacuum(collection)
+		if err != nil {
+			d.log.Warn("Auto-compaction of '%s' failed: %v", collection, err)
+		}
+		total += n
+	}
+	if total > 0 {
+		d.log.Info("Auto-compaction reclaimed %d bytes across %d collections", total, len(collections))
+	} else {
+		d.log.Debug("Auto-compaction found nothing to reclaim")
+	}
+}
+
+// vacuum removes leftover .tmp files from collection and, when it is
+// configured as Compact, compacts its records. It returns the number of
+// bytes reclaimed.
+func (d *Driver) vacuum(collection string) (int64, error) {
+	mutex := d.getOrCreateMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	dirs := []string{d.collectionDir(collection)}
+	if d.opts.Sharding > 0 {
+		shards, err := d.recordDirs(collection)
+		if err != nil {
+			return 0, err
+		}
+		dirs = append(dirs, shards...)
+	}
+
+	var reclaimed int64
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return reclaimed, err
+		}
+
+		for _, entry := range entries {
+			if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".tmp") {
+				continue
+			}
+			if info, err := entry.Info(); err == nil {
+				reclaimed += info.Size()
+			}
+			d.log.Debug("Removing stale temp file: %s", entry.Name())
+			if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil {
+				return reclaimed, err
+			}
+		}
+	}
+
+	if !d.config(collection).Compact {
+		return reclaimed, nil
+	}
+
+	names, err := d.list(collection)
+	if err != nil {
+		return reclaimed, err
+	}
+	for _, name := range names {
+		n, err := d.compact(collection, name)
+		if err != nil {
+			return reclaimed, err
+		}
+		reclaimed += int64(n)
+	}
+	return reclaimed, nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jcelliott/lumber"
+)
+
+const (
+	oldRecord = `{"v":"old"}`
+	newRecord = `{"v":"new"}`
+	crashCode = 3
+)
+
+// TestCrashMidWrite kills a child process once a record's new contents are
+// written but not yet in place, then reopens the database and checks that
+// the record holds its old or new contents, never anything in between. The
+// child is this test binary, re-run with CRASH_DIR set.
+func TestCrashMidWrite(t *testing.T) {
+	if dir := os.Getenv("CRASH_DIR"); dir != "" {
+		crashWrite(dir, os.Getenv("CRASH_MODE"))
+		t.Fatal("write completed without crashing")
+	}
+
+	for _, mode := range []string{"tmpfile", "rename"} {
+		for _, existing := range []bool{true, false} {
+			for _, policy := range []StaleTmpPolicy{IgnoreStaleTmp, PromoteStaleTmp} {
+				dir := filepath.Join(t.TempDir(), "db")
+				if existing {
+					d, err := New(dir, &Options{LogLevel: lumber.FATAL})
+					if err != nil {
+						t.Fatal(err)
+					}
+					if err := d.WriteRaw("users", "john", []byte(oldRecord)); err != nil {
+						t.Fatal(err)
+					}
+					d.Close()
+				}
+
+				cmd := exec.Command(os.Args[0], "-test.run=^TestCrashMidWrite$")
+				cmd.Env = append(os.Environ(), "CRASH_DIR="+dir, "CRASH_MODE="+mode)
+				out, err := cmd.CombinedOutput()
+				var exit *exec.ExitError
+				if !errors.As(err, &exit) || exit.ExitCode() != crashCode {
+					t.Fatalf("%v: child didn't crash mid-write: %v\n%s", mode, err, out)
+				}
+
+				d, err := New(dir, &Options{LogLevel: lumber.FATAL, StaleTmp: policy})
+				if err != nil {
+					t.Fatal(err)
+				}
+				b, err := d.ReadRaw("users", "john")
+				d.Close()
+
+				got := strings.Join(strings.Fields(string(b)), "")
+				switch {
+				case err == nil && (got == oldRecord || got == newRecord):
+				case err != nil && errors.Is(err, ErrNotFound) && !existing:
+				default:
+					t.Errorf("%v, existing=%v, policy=%v: got %q, %v after crash", mode, existing, policy, b, err)
+				}
+			}
+		}
+	}
+}
+
+// crashWrite writes the new record to the database in dir, exiting the
+// process just before it would replace the old one.
+func crashWrite(dir, mode string) {
+	d, err := New(dir, &Options{
+		LogLevel: lumber.FATAL,
+		failAfterTempWrite: func(path string) error {
+			if strings.HasSuffix(path, "john.json") {
+				os.Exit(crashCode)
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		return
+	}
+
+	switch mode {
+	case "tmpfile":
+		d.WriteRaw("users", "john", []byte(newRecord))
+	case "rename":
+		path := d.recordPath("users", "john") + ".json"
+		os.MkdirAll(filepath.Dir(path), d.dirMode)
+		d.writeFileRename(path, []byte(newRecord))
+	}
+}
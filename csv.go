@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// ExportCSV writes the named top-level fields of every record in collection
+// to w as CSV, one row per record after a header row of the field names.
+// Missing and null fields are written as empty cells, nested objects and
+// arrays as compact JSON.
+func (d *Driver) ExportCSV(collection string, fields []string, w io.Writer) error {
+	bw := bufio.NewWriterSize(w, d.opts.WriteBufferSize)
+	cw := csv.NewWriter(bw)
+	if err := cw.Write(fields); err != nil {
+		return err
+	}
+
+	row := make([]string, len(fields))
+	err := d.Scan(collection, func(name string, b []byte) (bool, error) {
+		var obj map[string]interface{}
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.UseNumber()
+		if err := dec.Decode(&obj); err != nil {
+			return false, fmt.Errorf("invalid record %v/%v: %v", collection, name, err)
+		}
+
+		for i, field := range fields {
+			cell, err := csvCell(obj[field])
+			if err != nil {
+				return false, err
+			}
+			row[i] = cell
+		}
+		return false, cw.Write(row)
+	})
+	if err != nil {
+		return err
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func csvCell(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	default:
+		b, err := json.Marshal(v)
+		return string(b), err
+	}
+}
+
+// ImportCSV reads a CSV with a header row from r and writes every row to
+// collection as a JSON object of its non-empty cells, keyed by the value in
+// the keyField column. Cells are stored as strings. Rows are written one at
+// a time, so on error the rows before it have already been imported.
+func (d *Driver) ImportCSV(collection string, r io.Reader, keyField string) (int, error) {
+	cr := csv.NewReader(bufio.NewReaderSize(r, d.opts.ReadBufferSize))
+	header, err := cr.Read()
+	if err != nil {
+		return 0, err
+	}
+
+	key := -1
+	for i, field := range header {
+		if field == keyField {
+			key = i
+			break
+		}
+	}
+	if key < 0 {
+		return 0, fmt.Errorf("missing key column %v", keyField)
+	}
+
+	n := 0
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+
+		line, _ := cr.FieldPos(0)
+		if row[key] == "" {
+			return n, fmt.Errorf("line %v: missing key %v", line, keyField)
+		}
+
+		obj := make(map[string]string, len(row))
+		for i, cell := range row {
+			if cell != "" {
+				obj[header[i]] = cell
+			}
+		}
+		if err := d.Write(collection, row[key], obj); err != nil {
+			return n, fmt.Errorf("line %v: %w", line, err)
+		}
+		n++
+	}
+}
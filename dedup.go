@@ -0,0 +1,51 @@
+package main
+
+import "fmt"
+
+// Deduplicate groups the records of collection by the key keyFn derives
+// from their stored bytes and deletes all but the first record, in name
+// order, of each group. It returns the names it deleted. The whole pass
+// runs under the collection lock; if keyFn fails, Deduplicate stops with
+// its error, and the records deleted so far are still reported.
+func (d *Driver) Deduplicate(collection string, keyFn func(raw []byte) (string, error)) (removed []string, err error) {
+	if collection == "" {
+		return nil, fmt.Errorf("%w - unable to deduplicate", ErrMissingCollection)
+	}
+	if err := validNames(collection); err != nil {
+		return nil, err
+	}
+
+	mutex := d.getOrCreateMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	names, err := d.list(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	keep := make(map[string]string)
+	for _, name := range names {
+		b, err := d.read(collection, name)
+		if err != nil {
+			return removed, err
+		}
+		key, err := keyFn(b)
+		if err != nil {
+			return removed, fmt.Errorf("unable to derive key of %v/%v: %w", collection, name, err)
+		}
+
+		first, ok := keep[key]
+		if !ok {
+			keep[key] = name
+			continue
+		}
+
+		d.log.Debug("Removing %s/%s, a duplicate of %s", collection, name, first)
+		if err := d.remove(collection, name); err != nil {
+			return removed, err
+		}
+		removed = append(removed, name)
+	}
+	return removed, nil
+}
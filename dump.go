@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// DumpJSON writes the whole database to w as a single JSON object of the
+// form {collection: {resource: record}}. Records are streamed one at a time
+// rather than collected in memory first.
+func (d *Driver) DumpJSON(w io.Writer) error {
+	collections, err := d.Collections()
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriterSize(w, d.opts.WriteBufferSize)
+	bw.WriteByte('{')
+	for i, collection := range collections {
+		if i > 0 {
+			bw.WriteByte(',')
+		}
+		if err := d.dumpCollection(bw, collection); err != nil {
+			return err
+		}
+	}
+	bw.WriteString("}\n")
+	return bw.Flush()
+}
+
+// DumpJSONGzip is DumpJSON compressed with gzip as it is written, so memory
+// use stays bounded however large the database is.
+func (d *Driver) DumpJSONGzip(w io.Writer) error {
+	zw := gzip.NewWriter(w)
+	if err := d.DumpJSON(zw); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func (d *Driver) dumpCollection(w *bufio.Writer, collection string) error {
+	mutex := d.getOrCreateMutex(collection)
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	names, err := d.list(collection)
+	if err != nil {
+		return err
+	}
+
+	writeKey(w, collection)
+	w.WriteByte('{')
+	var buf bytes.Buffer
+	for i, name := range names {
+		b, err := d.read(collection, name)
+		if err != nil {
+			return err
+		}
+
+		buf.Reset()
+		if err := json.Compact(&buf, b); err != nil {
+			return fmt.Errorf("invalid record %v/%v: %v", collection, name, err)
+		}
+
+		if i > 0 {
+			w.WriteByte(',')
+		}
+		writeKey(w, name)
+		w.Write(buf.Bytes())
+	}
+	w.WriteByte('}')
+	return nil
+}
+
+func writeKey(w *bufio.Writer, key string) {
+	b, _ := json.Marshal(key)
+	w.Write(b)
+	w.WriteByte(':')
+}
+
+// LoadJSON reads a document in the format produced by DumpJSON from r and
+// writes every record it contains, each one atomically.
+func (d *Driver) LoadJSON(r io.Reader) error {
+	dec := json.NewDecoder(bufio.NewReaderSize(r, d.opts.ReadBufferSize))
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+
+	for dec.More() {
+		collection, err := stringToken(dec)
+		if err != nil {
+			return err
+		}
+		if err := expectDelim(dec, '{'); err != nil {
+			return err
+		}
+
+		for dec.More() {
+			resource, err := stringToken(dec)
+			if err != nil {
+				return err
+			}
+
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); err != nil {
+				return fmt.Errorf("invalid record %v/%v: %v", collection, resource, err)
+			}
+			if err := d.Write(collection, resource, raw); err != nil {
+				return err
+			}
+		}
+
+		if err := expectDelim(dec, '}'); err != nil {
+			return err
+		}
+	}
+	return expectDelim(dec, '}')
+}
+
+// LoadJSONGzip is LoadJSON for a document written by DumpJSONGzip.
+func (d *Driver) LoadJSONGzip(r io.Reader) error {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	return d.LoadJSON(zr)
+}
+
+func expectDelim(dec *json.Decoder, want json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != want {
+		return fmt.Errorf("invalid JSON - expected %v, got %v", want, tok)
+	}
+	return nil
+}
+
+func stringToken(dec *json.Decoder) (string, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return "", err
+	}
+	s, ok := tok.(string)
+	if !ok {
+		return "", fmt.Errorf("invalid JSON - expected a name, got %v", tok)
+	}
+	return s, nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+)
+
+// Equal reports whether two records of collection hold the same JSON value,
+// ignoring formatting, whitespace and object key order. Numbers are compared
+// exactly, so 1, 1.0 and 1e0 are equal but integers beyond float64 precision
+// are not rounded together. The KeyField, if configured, is left out of the
+// comparison.
+func (d *Driver) Equal(collection, resourceA, resourceB string) (bool, error) {
+	a, err := d.decodeAny(collection, resourceA)
+	if err != nil {
+		return false, err
+	}
+	b, err := d.decodeAny(collection, resourceB)
+	if err != nil {
+		return false, err
+	}
+	return equalJSON(a, b), nil
+}
+
+func (d *Driver) decodeAny(collection, resource string) (interface{}, error) {
+	b, err := d.ReadRaw(collection, resource)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.keyField != "" {
+		if b, err = stripKey(b, d.keyField, d.fold(resource)); err != nil {
+			return nil, err
+		}
+	}
+
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("unable to decode %v: %v", resource, err)
+	}
+	return v, nil
+}
+
+// equalJSON compares two values decoded with UseNumber.
+func equalJSON(a, b interface{}) bool {
+	switch a := a.(type) {
+	case map[string]interface{}:
+		b, ok := b.(map[string]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k, av := range a {
+			bv, ok := b[k]
+			if !ok || !equalJSON(av, bv) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !equalJSON(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case json.Number:
+		b, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		x, okA := new(big.Rat).SetString(string(a))
+		y, okB := new(big.Rat).SetString(string(b))
+		return okA && okB && x.Cmp(y) == 0
+	default:
+		return a == b
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestEqual(t *testing.T) {
+	d := newTestDriver(t, nil)
+	for _, tc := range []struct {
+		a, b string
+		want bool
+	}{
+		{`{"a":1,"b":[true,null,"x"]}`, `{"b":[true,null,"x"],"a":1}`, true},
+		{`{"n":9007199254740993}`, `{"n":9007199254740992}`, false},
+		{`{"n":9007199254740993}`, `{"n":9007199254740993}`, true},
+		{`{"n":1}`, `{"n":1.0}`, true},
+		{`{"n":100}`, `{"n":1e2}`, true},
+		{`{"n":1}`, `{"n":"1"}`, false},
+		{`[1,2]`, `[2,1]`, false},
+		{`{"a":{}}`, `{"a":[]}`, false},
+	} {
+		if err := d.WriteRaw("records", "a", []byte(tc.a)); err != nil {
+			t.Fatal(err)
+		}
+		if err := d.WriteRaw("records", "b", []byte(tc.b)); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := d.Equal("records", "a", "b"); err != nil || got != tc.want {
+			t.Errorf("Equal(%s, %s) = %v, %v, want %v", tc.a, tc.b, got, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import "errors"
+
+// ErrMissingCollection is returned when an operation is given an empty
+// collection name.
+var ErrMissingCollection = errors.New("missing collection")
+
+// ErrMissingResource is returned when an operation that needs a record is
+// given an empty resource name.
+var ErrMissingResource = errors.New("missing resource")
+
+// ErrInvalidName is returned for collection and resource names that cannot
+// be stored safely, such as ones containing a path separator.
+var ErrInvalidName = errors.New("invalid name")
+
+// ErrNotFound is returned when a record does not exist. It wraps the
+// underlying fs.ErrNotExist, so errors.Is matches either.
+var ErrNotFound = errors.New("record not found")
+
+// ErrAlreadyExists is returned when writing a record that exists under the
+// FailIfExists write policy, and by CreateCollection for an existing
+// collection.
+var ErrAlreadyExists = errors.New("record already exists")
+
+// ErrConstraintViolation is returned when a write would break a unique
+// constraint set with SetUniqueConstraint.
+var ErrConstraintViolation = errors.New("unique constraint violation")
+
+// ErrQuotaExceeded is returned when a write would take a collection past
+// Options.MaxRecordsPerCollection.
+var ErrQuotaExceeded = errors.New("collection record quota exceeded")
+
+// ErrClosed is returned by operations on a Driver after Close.
+var ErrClosed = errors.New("database is closed")
+
+// ErrReadOnly is returned by operations that would modify a database opened
+// with Options.ReadOnly.
+var ErrReadOnly = errors.New("database is read-only")
+
+// ErrRateLimited is returned when a collection is over Options.RateLimit
+// and RateLimitMode is RateLimitReject.
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+// ErrFieldNotFound is returned when a record has no such top-level field.
+var ErrFieldNotFound = errors.New("field not found")
+
+// ErrEmptyRecord is returned when reading a record whose file is empty.
+var ErrEmptyRecord = errors.New("empty record")
+
+// ErrChecksumMismatch is returned by reads when Options.Checksum is set and a
+// record no longer matches the checksum stored when it was written.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// ErrInvalidTarget is returned by Read and friends when v is not a non-nil
+// pointer, before anything is read.
+var ErrInvalidTarget = errors.New("Read requires a non-nil pointer")
+
+// ErrInsufficientSpace is returned by writes that would leave less than
+// Options.MinFreeBytes free on disk.
+var ErrInsufficientSpace = errors.New("insufficient disk space")
+
+// ErrConflict is returned by WriteIfUnchanged when the record has changed
+// since it was read with ReadForUpdate.
+var ErrConflict = errors.New("record changed since read")
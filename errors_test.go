@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jcelliott/lumber"
+)
+
+func TestErrors(t *testing.T) {
+	d := newTestDriver(t, nil)
+	if err := d.Write("users", "john", map[string]int{"age": 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	var v map[string]int
+	for _, tc := range []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"Write no collection", d.Write("", "john", v), ErrMissingCollection},
+		{"Write no resource", d.Write("users", "", v), ErrMissingResource},
+		{"Read no collection", d.Read("", "john", &v), ErrMissingCollection},
+		{"Read no resource", d.Read("users", "", &v), ErrMissingResource},
+		{"Delete no collection", d.Delete("", "john"), ErrMissingCollection},
+		{"ReadAll no collection", second(d.ReadAll("")), ErrMissingCollection},
+		{"Read missing record", d.Read("users", "jane", &v), ErrNotFound},
+		{"Read missing collection", d.Read("posts", "jane", &v), ErrNotFound},
+		{"Delete missing record", d.Delete("users", "jane"), ErrNotFound},
+		{"Delete missing collection", d.Delete("posts", ""), ErrNotFound},
+	} {
+		if !errors.Is(tc.err, tc.want) {
+			t.Errorf("%v: got %v, want %v", tc.name, tc.err, tc.want)
+		}
+	}
+}
+
+func TestReadOnlyErrors(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "db")
+	rw, err := New(dir, &Options{LogLevel: lumber.FATAL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rw.Write("users", "john", map[string]int{"age": 1}); err != nil {
+		t.Fatal(err)
+	}
+	rw.Close()
+
+	d := newTestDriverIn(t, dir, &Options{ReadOnly: true})
+	for _, tc := range []struct {
+		name string
+		err  error
+	}{
+		{"Write", d.Write("users", "jane", map[string]int{})},
+		{"Delete", d.Delete("users", "john")},
+		{"DeleteCollection", d.Delete("users", "")},
+		{"ConfigureCollection", d.ConfigureCollection("posts", CollectionConfig{Compact: true})},
+		{"NextSequence", second(d.NextSequence("posts"))},
+		{"GlobalSequence", second(d.GlobalSequence("posts"))},
+		{"CreateIndex", d.CreateIndex("posts", "age")},
+		{"Reindex", d.Reindex("users")},
+		{"SetUniqueConstraint", d.SetUniqueConstraint("posts", []string{"age"})},
+		{"CreateSortedIndex", d.CreateSortedIndex("posts", "age")},
+	} {
+		if !errors.Is(tc.err, ErrReadOnly) {
+			t.Errorf("%v: got %v, want ErrReadOnly", tc.name, tc.err)
+		}
+	}
+
+	if _, err := os.Stat(d.collectionDir("posts")); !os.IsNotExist(err) {
+		t.Errorf("read-only driver created a collection directory: %v", err)
+	}
+	var v map[string]int
+	if err := d.Read("users", "john", &v); err != nil || v["age"] != 1 {
+		t.Errorf("Read = %v, %v", v, err)
+	}
+}
+
+// second returns the error of a two-value call.
+func second[T any](_ T, err error) error { return err }
@@ -0,0 +1,92 @@
+package main
+
+import "errors"
+
+// Tiered storage
+//
+// With Options.Fallback set, the driver acts as a cache in front of another
+// Driver. Read serves the local copy when there is one and otherwise reads
+// the fallback, copying the record locally if PopulateFromFallback is set.
+// Write always goes to the local store first; with WriteThrough it is then
+// repeated on the fallback, and an error there is returned even though the
+// local write already happened. Delete only affects the local store, so a
+// record that still exists in the fallback will be served again by Read.
+//
+// There is no invalidation: once a record is cached locally, later changes
+// made directly to the fallback are not seen. ReadAll returns the union of
+// both stores, preferring the local copy of records present in both.
+
+// readThrough reads a record from the local store, falling back to
+// d.fallback when it is missing locally.
+func (d *Driver) readThrough(collection, resource string) ([]byte, error) {
+	if d.opts.StaleTmp != IgnoreStaleTmp {
+		d.checkStaleTmp(collection, resource)
+	}
+
+	b, err := d.read(collection, resource)
+	if d.fallback == nil || !errors.Is(err, ErrNotFound) {
+		return b, err
+	}
+
+	d.log.Debug("Reading %s/%s from fallback", collection, resource)
+	b, err = d.fallback.ReadRaw(collection, resource)
+	if err != nil || !d.populate || d.opts.ReadOnly {
+		return b, err
+	}
+
+	mutex := d.getOrCreateMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if d.exists(collection, resource) {
+		return b, nil
+	}
+	if err := d.write(collection, resource, b); err != nil {
+		d.log.Warn("Failed to populate %s/%s from fallback: %v", collection, resource, err)
+	}
+	return b, nil
+}
+
+// readLocked is readThrough for callers that may hold the collection lock.
+// It never takes the lock, so it doesn't populate the local store from the
+// fallback, and only warns about stale temp files instead of promoting them.
+func (d *Driver) readLocked(collection, resource string) ([]byte, error) {
+	if d.opts.StaleTmp != IgnoreStaleTmp && newerTmp(d.recordPath(collection, resource)+".json") {
+		d.log.Warn("Found newer temp file for %s/%s, ignoring it", collection, resource)
+	}
+
+	b, err := d.read(collection, resource)
+	if d.fallback == nil || !errors.Is(err, ErrNotFound) {
+		return b, err
+	}
+
+	d.log.Debug("Reading %s/%s from fallback", collection, resource)
+	return d.fallback.ReadRaw(collection, resource)
+}
+
+// fallbackOnly returns the records of collection that exist in the fallback
+// but not in local.
+func (d *Driver) fallbackOnly(collection string, local []string) ([][]byte, error) {
+	names, err := d.fallback.List(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	have := make(map[string]bool, len(local))
+	for _, name := range local {
+		have[name] = true
+	}
+
+	var records [][]byte
+	for _, name := range names {
+		if have[name] {
+			continue
+		}
+		b, err := d.fallback.ReadRaw(collection, name)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, b)
+	}
+	return records, nil
+}
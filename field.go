@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// ReadField returns the raw JSON value of a top-level field of a record.
+// The record is read like Read does, then decoded token by token and only
+// up to the field, so large records are never fully unmarshalled.
+func (d *Driver) ReadField(collection, resource, field string) (json.RawMessage, error) {
+	if collection == "" {
+		return nil, fmt.Errorf("%w - unable to read", ErrMissingCollection)
+	}
+	if resource == "" {
+		return nil, fmt.Errorf("%w - unable to read (no name)", ErrMissingResource)
+	}
+	if err := validNames(collection, resource); err != nil {
+		return nil, err
+	}
+
+	b, err := d.readThrough(collection, resource)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := decodeField(bytes.NewReader(b), field)
+	if err != nil {
+		return nil, fmt.Errorf("%v/%v: %w", collection, resource, err)
+	}
+	return raw, nil
+}
+
+// decodeField streams the JSON object in r until it finds field.
+func decodeField(r io.Reader, field string) (json.RawMessage, error) {
+	dec := json.NewDecoder(r)
+	if err := expectDelim(dec, '{'); err != nil {
+		return nil, err
+	}
+
+	for dec.More() {
+		key, err := stringToken(dec)
+		if err != nil {
+			return nil, err
+		}
+
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, err
+		}
+		if key == field {
+			return raw, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %v", ErrFieldNotFound, field)
+}
+
+// FieldStats streams collection and aggregates the numeric top-level field
+// of its records. Records where the field is missing or not a number, and
+// records that aren't objects, are skipped and not counted. min and max are
+// 0 when no record counts.
+func (d *Driver) FieldStats(collection, field string) (min, max, sum float64, count int, err error) {
+	err = d.Scan(collection, func(name string, b []byte) (bool, error) {
+		raw, err := decodeField(bytes.NewReader(b), field)
+		if err != nil {
+			return false, nil
+		}
+
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.UseNumber()
+		var v interface{}
+		if err := dec.Decode(&v); err != nil {
+			return false, nil
+		}
+		n, ok := v.(json.Number)
+		if !ok {
+			return false, nil
+		}
+		f, err := n.Float64()
+		if err != nil {
+			return false, nil
+		}
+
+		if count == 0 || f < min {
+			min = f
+		}
+		if count == 0 || f > max {
+			max = f
+		}
+		sum += f
+		count++
+		return false, nil
+	})
+	return min, max, sum, count, err
+}
+
+// UpdateField replaces a top-level field of a record with the value
+// returned by fn, which receives the current value (nil if the field is
+// absent). Returning nil removes the field. The read, fn and write all happen
+// under the collection lock, so concurrent updates never interleave.
+func (d *Driver) UpdateField(collection, resource, field string, fn func(current json.RawMessage) (json.RawMessage, error)) error {
+	if collection == "" {
+		return fmt.Errorf("%w - unable to update", ErrMissingCollection)
+	}
+	if resource == "" {
+		return fmt.Errorf("%w - unable to update (no name)", ErrMissingResource)
+	}
+	if err := validNames(collection, resource); err != nil {
+		return err
+	}
+
+	mutex := d.getOrCreateMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	b, err := d.read(collection, resource)
+	if err != nil {
+		return err
+	}
+
+	obj, err := decodeObject(b)
+	if err != nil {
+		return fmt.Errorf("%v/%v: %v", collection, resource, err)
+	}
+
+	i := obj.index(field)
+	var current json.RawMessage
+	if i >= 0 {
+		current = obj[i].Value
+	}
+
+	value, err := fn(current)
+	if err != nil {
+		return err
+	}
+	if value != nil && !json.Valid(value) {
+		return fmt.Errorf("invalid JSON - unable to update %v.%v", resource, field)
+	}
+
+	switch {
+	case value == nil && i >= 0:
+		obj = append(obj[:i], obj[i+1:]...)
+	case value == nil:
+		// Removing a field that isn't there.
+	case i >= 0:
+		obj[i].Value = value
+	default:
+		obj = append(obj, objectField{field, value})
+	}
+
+	b, err = d.marshal(collection, resource, obj.raw())
+	if err != nil {
+		return err
+	}
+	return d.write(collection, resource, b)
+}
+
+type objectField struct {
+	Key   string
+	Value json.RawMessage
+}
+
+// object is a decoded JSON object that keeps its fields in order.
+type object []objectField
+
+func decodeObject(b []byte) (object, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	if err := expectDelim(dec, '{'); err != nil {
+		return nil, err
+	}
+
+	var obj object
+	for dec.More() {
+		key, err := stringToken(dec)
+		if err != nil {
+			return nil, err
+		}
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, err
+		}
+		obj = append(obj, objectField{key, raw})
+	}
+	return obj, expectDelim(dec, '}')
+}
+
+func (obj object) index(key string) int {
+	for i, f := range obj {
+		if f.Key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+func (obj object) raw() json.RawMessage {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, f := range obj {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, _ := json.Marshal(f.Key)
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(f.Value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes()
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestReadField(t *testing.T) {
+	fallback := newTestDriver(t, nil)
+	if err := fallback.Write("users", "jane", map[string]int{"age": 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, opts := range map[string]*Options{
+		"plain":       {},
+		"single file": {SingleFilePerCollection: true},
+		"fallback":    {Fallback: fallback},
+		"checksum":    {Checksum: true},
+	} {
+		d := newTestDriver(t, opts)
+		if err := d.Write("users", "john", map[string]int{"age": 1}); err != nil {
+			t.Fatal(err)
+		}
+		if raw, err := d.ReadField("users", "john", "age"); err != nil || string(raw) != "1" {
+			t.Errorf("%v: ReadField = %s, %v, want 1", name, raw, err)
+		}
+		if _, err := d.ReadField("users", "john", "name"); !errors.Is(err, ErrFieldNotFound) {
+			t.Errorf("%v: missing field: got %v, want ErrFieldNotFound", name, err)
+		}
+
+		_, err := d.ReadField("users", "jane", "age")
+		if name == "fallback" {
+			if err != nil {
+				t.Errorf("%v: ReadField from fallback: %v", name, err)
+			}
+		} else if !errors.Is(err, ErrNotFound) {
+			t.Errorf("%v: missing record: got %v, want ErrNotFound", name, err)
+		}
+	}
+
+	d := newTestDriver(t, &Options{Checksum: true})
+	if err := d.Write("users", "john", map[string]int{"age": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(d.recordPath("users", "john")+".json", []byte(`{"age":9}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.ReadField("users", "john", "age"); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("tampered record: got %v, want ErrChecksumMismatch", err)
+	}
+}
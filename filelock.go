@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// With Options.FileLocking every write and delete also holds an advisory
+// OS lock on <db>/.locks/<collection>.lock, so that several processes can
+// share a database directory without interleaving their read-modify-write
+// updates of a collection. The lock is per collection rather than per record
+// file because records are replaced by rename, which would leave a lock on
+// the old file, and because index and metadata files are shared by the
+// whole collection. Reads take no lock; records are still replaced
+// atomically, so they never see a partial write.
+//
+// The lock is flock(2) on Linux, macOS and the BSDs and LockFileEx on
+// Windows. Elsewhere FileLocking only logs a warning and the in-process
+// collection locks are all that apply. Like any advisory lock it only
+// protects against processes that take it too.
+
+// lockFile takes the cross-process lock of collection and returns the
+// function releasing it. It must be called with the collection lock held.
+func (d *Driver) lockFile(collection string) (unlock func(), err error) {
+	if !d.opts.FileLocking {
+		return func() {}, nil
+	}
+
+	dir := filepath.Join(d.dir, ".locks")
+	if err := os.MkdirAll(dir, d.dirMode); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, d.fold(collection)+".lock"), os.O_RDWR|os.O_CREATE, d.fileMode)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := lockFile(f); err != nil {
+		f.Close()
+		if err == errNoFileLocking {
+			d.log.Warn("File locking is not supported on this platform")
+			return func() {}, nil
+		}
+		return nil, err
+	}
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
@@ -0,0 +1,18 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly || windows)
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+var errNoFileLocking = errors.New("file locking not supported")
+
+func lockFile(f *os.File) error {
+	return errNoFileLocking
+}
+
+func unlockFile(f *os.File) error {
+	return nil
+}
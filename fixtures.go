@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// LoadFixtures writes every collection/resource.json file found in fsys,
+// such as an embed.FS of test data, into the database. Files are loaded in
+// lexical order; other files, hidden ones and ones at other depths are
+// ignored.
+func (d *Driver) LoadFixtures(fsys fs.FS) error {
+	return fs.WalkDir(fsys, ".", func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != "." && strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() || path.Ext(p) != ".json" {
+			return nil
+		}
+
+		collection, file, ok := strings.Cut(p, "/")
+		if !ok || strings.Contains(file, "/") {
+			return nil
+		}
+
+		b, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		if !json.Valid(b) {
+			return fmt.Errorf("invalid fixture %v", p)
+		}
+		return d.Write(collection, strings.TrimSuffix(file, ".json"), json.RawMessage(b))
+	})
+}
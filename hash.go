@@ -0,0 +1,100 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+)
+
+// CollectionHash returns a SHA-256 digest, in hex, of the names and stored
+// bytes of every record in collection, in name order, so that collections
+// holding the same records byte for byte hash the same. Records are hashed
+// as stored, so with differently formatted files (Compact, OmitNewline or
+// key order) equal data can hash differently; Options.Canonicalize makes
+// the bytes stable.
+func (d *Driver) CollectionHash(collection string) (string, error) {
+	if collection == "" {
+		return "", fmt.Errorf("%w - unable to hash", ErrMissingCollection)
+	}
+	if err := validNames(collection); err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	err := d.Scan(collection, func(name string, b []byte) (bool, error) {
+		// Length-prefix both parts so that no two collections can
+		// produce the same stream.
+		for _, part := range [][]byte{[]byte(name), b} {
+			var n [8]byte
+			binary.BigEndian.PutUint64(n[:], uint64(len(part)))
+			h.Write(n[:])
+			h.Write(part)
+		}
+		return false, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Manifest returns the SHA-256 digest, in hex, of the stored bytes of every
+// record in collection, keyed by name, so that a client can compare it with
+// its own and fetch only the records that differ. Files are hashed one at a
+// time as they are read, in name order, under the collection read lock.
+func (d *Driver) Manifest(collection string) (map[string]string, error) {
+	if collection == "" {
+		return nil, fmt.Errorf("%w - unable to hash", ErrMissingCollection)
+	}
+	if err := validNames(collection); err != nil {
+		return nil, err
+	}
+
+	mutex := d.getOrCreateMutex(collection)
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	if d.opts.SingleFilePerCollection {
+		records, err := d.loadSingle(collection)
+		if err != nil {
+			return nil, err
+		}
+		manifest := make(map[string]string, len(records))
+		for name, b := range records {
+			manifest[name] = string(checksum(b))
+		}
+		return manifest, nil
+	}
+
+	names, err := d.list(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := make(map[string]string, len(names))
+	for _, name := range names {
+		sum, err := hashFile(d.recordPath(collection, name) + ".json")
+		if err != nil {
+			return nil, err
+		}
+		manifest[name] = sum
+	}
+	return manifest, nil
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+)
+
+// HTTPHandler returns a handler exposing the database for debugging:
+//
+//	GET /                        list of collections
+//	GET /{collection}            list of record names
+//	GET /{collection}/{resource} the record itself
+//
+// With Options.HTTPWrites set it also accepts PUT (body is the record) and
+// DELETE on /{collection}/{resource}.
+func (d *Driver) HTTPHandler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		names, err := d.Collections()
+		writeNames(w, names, err)
+	})
+	mux.HandleFunc("GET /{collection}", func(w http.ResponseWriter, r *http.Request) {
+		names, err := d.List(r.PathValue("collection"))
+		writeNames(w, names, err)
+	})
+	mux.HandleFunc("GET /{collection}/{resource}", func(w http.ResponseWriter, r *http.Request) {
+		b, err := d.ReadRaw(r.PathValue("collection"), r.PathValue("resource"))
+		if err != nil {
+			httpError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(b)
+	})
+
+	if d.opts.HTTPWrites {
+		mux.HandleFunc("PUT /{collection}/{resource}", func(w http.ResponseWriter, r *http.Request) {
+			b, err := io.ReadAll(r.Body)
+			if err != nil {
+				httpError(w, err)
+				return
+			}
+			if !json.Valid(b) {
+				http.Error(w, "invalid JSON", http.StatusBadRequest)
+				return
+			}
+			if err := d.Write(r.PathValue("collection"), r.PathValue("resource"), json.RawMessage(b)); err != nil {
+				httpError(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+		mux.HandleFunc("DELETE /{collection}/{resource}", func(w http.ResponseWriter, r *http.Request) {
+			if err := d.Delete(r.PathValue("collection"), r.PathValue("resource")); err != nil {
+				httpError(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+
+	return mux
+}
+
+func writeNames(w http.ResponseWriter, names []string, err error) {
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	if names == nil {
+		names = []string{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(names)
+}
+
+func httpError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrNotFound) || errors.Is(err, fs.ErrNotExist) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, ErrInvalidName) || errors.Is(err, ErrMissingCollection) || errors.Is(err, ErrMissingResource) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHTTPHandler(t *testing.T) {
+	d := newTestDriver(t, &Options{HTTPWrites: true})
+	if err := os.WriteFile(filepath.Join(filepath.Dir(d.dir), "secret.json"), []byte(`{}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Write("users", "john", map[string]string{"name": "John"}); err != nil {
+		t.Fatal(err)
+	}
+	h := d.HTTPHandler()
+
+	tests := []struct {
+		method, path string
+		want         int
+		body         string
+	}{
+		{"GET", "/", http.StatusOK, `["users"]`},
+		{"GET", "/users", http.StatusOK, `["john"]`},
+		{"GET", "/users/john", http.StatusOK, `"John"`},
+		{"GET", "/users/jane", http.StatusNotFound, ""},
+		{"GET", "/missing", http.StatusNotFound, ""},
+		{"GET", "/..%2F", http.StatusBadRequest, ""},
+		{"GET", "/users/..%2F..%2Fsecret", http.StatusBadRequest, ""},
+		{"PUT", "/..%2F/secret", http.StatusBadRequest, ""},
+		{"DELETE", "/..%2F/secret", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "http://db"+tt.path, strings.NewReader(`{}`))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		if rec.Code != tt.want {
+			t.Errorf("%s %s: status %d, want %d (%s)", tt.method, tt.path, rec.Code, tt.want, rec.Body)
+		}
+		if tt.body != "" && !strings.Contains(rec.Body.String(), tt.body) {
+			t.Errorf("%s %s: body %q, want it to contain %s", tt.method, tt.path, rec.Body, tt.body)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(d.dir), "secret.json")); err != nil {
+		t.Errorf("secret.json: %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+// maxInsertAttempts bounds how many ids Insert generates before giving up
+// on finding an unused one.
+const maxInsertAttempts = 5
+
+// Insert writes v under a newly generated unique resource name and returns
+// that name. Names come from Options.IDGenerator, or are random UUIDs; a
+// name already in use is retried a few times before Insert fails.
+func (d *Driver) Insert(collection string, v interface{}) (string, error) {
+	if collection == "" {
+		return "", fmt.Errorf("%w - no place to save records", ErrMissingCollection)
+	}
+	if err := validNames(collection); err != nil {
+		return "", err
+	}
+
+	mutex := d.getOrCreateMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	var id string
+	for attempt := 1; ; attempt++ {
+		var err error
+		if id, err = d.newID(); err != nil {
+			return "", err
+		}
+		if !d.exists(collection, id) {
+			break
+		}
+		if attempt == maxInsertAttempts {
+			return "", fmt.Errorf("generated id %v already exists in %v after %v attempts", id, collection, attempt)
+		}
+		d.log.Debug("Generated id '%s' already exists in '%s', retrying", id, collection)
+	}
+
+	b, err := d.marshal(collection, id, v)
+	if err != nil {
+		d.log.Error("JSON Marshalling failed: %v", err)
+		return "", err
+	}
+	if err := d.put(collection, id, b); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// WriteAuto writes v under the resource name keyFn derives from it, so that
+// callers can't pass a name that doesn't match the record.
+func (d *Driver) WriteAuto(collection string, v interface{}, keyFn func(interface{}) (string, error)) error {
+	resource, err := keyFn(v)
+	if err != nil {
+		return fmt.Errorf("unable to derive a name for the record: %w", err)
+	}
+	return d.Write(collection, resource, v)
+}
+
+func (d *Driver) newID() (string, error) {
+	if d.opts.IDGenerator == nil {
+		return newUUID()
+	}
+	id := d.opts.IDGenerator()
+	if err := ValidName(id); err != nil {
+		return "", fmt.Errorf("generated id %q: %w", id, err)
+	}
+	return id, nil
+}
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// index maps a field value to the sorted names of the records holding it.
+type index map[string][]string
+
+// CreateIndex registers an equality index on a top-level field of the
+// records in collection and builds it. Registered indexes are kept up to
+// date by Write and Delete.
+func (d *Driver) CreateIndex(collection, field string) error {
+	if collection == "" {
+		return fmt.Errorf("%w - unable to create index", ErrMissingCollection)
+	}
+	if field == "" {
+		return fmt.Errorf("missing field - unable to create index")
+	}
+	if err := validNames(collection, field); err != nil {
+		return err
+	}
+	if d.opts.ReadOnly {
+		return ErrReadOnly
+	}
+
+	if err := d.begin(); err != nil {
+		return err
+	}
+	defer d.end()
+
+	mutex := d.getOrCreateMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	meta := d.meta(collection)
+	for _, f := range meta.Indexes {
+		if f == field {
+			return nil
+		}
+	}
+
+	idx, err := d.buildIndexes(collection, []string{field})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(d.collectionDir(collection), d.dirMode); err != nil {
+		return err
+	}
+	if err := d.saveIndex(collection, field, idx[field]); err != nil {
+		return err
+	}
+
+	meta.Indexes = append(meta.Indexes, field)
+	return d.saveMeta(collection, meta)
+}
+
+// FindByIndex returns the names of the records whose indexed field equals
+// value.
+func (d *Driver) FindByIndex(collection, field, value string) ([]string, error) {
+	if collection == "" {
+		return nil, fmt.Errorf("%w - unable to query index", ErrMissingCollection)
+	}
+	if err := validNames(collection, field); err != nil {
+		return nil, err
+	}
+
+	if err := d.begin(); err != nil {
+		return nil, err
+	}
+	defer d.end()
+
+	mutex := d.getOrCreateMutex(collection)
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	if !d.hasIndex(collection, field) {
+		return nil, fmt.Errorf("no index on %v.%v", collection, field)
+	}
+
+	idx, err := d.loadIndex(collection, field)
+	if err != nil {
+		return nil, err
+	}
+	return idx[value], nil
+}
+
+// CountByIndex returns the number of records whose field equals value,
+// from the index on field. Without one it falls back to scanning the
+// collection, and logs a warning.
+func (d *Driver) CountByIndex(collection, field, value string) (int, error) {
+	if collection == "" {
+		return 0, fmt.Errorf("%w - unable to count", ErrMissingCollection)
+	}
+	if err := validNames(collection, field); err != nil {
+		return 0, err
+	}
+
+	if err := d.begin(); err != nil {
+		return 0, err
+	}
+	defer d.end()
+
+	mutex := d.getOrCreateMutex(collection)
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	if d.hasIndex(collection, field) {
+		idx, err := d.loadIndex(collection, field)
+		if err != nil {
+			return 0, err
+		}
+		return len(idx[value]), nil
+	}
+
+	d.log.Warn("No index on %s.%s, scanning the collection", collection, field)
+	n := 0
+	err := d.scan(collection, func(name string, b []byte) (bool, error) {
+		if key, ok := indexKey(b, field); ok && key == value {
+			n++
+		}
+		return false, nil
+	})
+	return n, err
+}
+
+// Reindex rebuilds every registered index of collection from the records on
+// disk and replaces the index files. Use it to recover from indexes that
+// drifted after a crash or after records were edited by hand.
+func (d *Driver) Reindex(collection string) error {
+	if collection == "" {
+		return fmt.Errorf("%w - unable to reindex", ErrMissingCollection)
+	}
+	if err := validNames(collection); err != nil {
+		return err
+	}
+	if d.opts.ReadOnly {
+		return ErrReadOnly
+	}
+
+	if err := d.begin(); err != nil {
+		return err
+	}
+	defer d.end()
+
+	mutex := d.getOrCreateMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	meta := d.meta(collection)
+	count := len(meta.Indexes) + len(meta.Unique) + len(meta.Sorted)
+	if count == 0 {
+		return nil
+	}
+
+	d.log.Info("Reindexing '%s' (%d indexes)", collection, count)
+	indexes, err := d.buildIndexes(collection, meta.Indexes)
+	if err != nil {
+		return err
+	}
+
+	for _, field := range meta.Indexes {
+		if err := d.saveIndex(collection, field, indexes[field]); err != nil {
+			return err
+		}
+	}
+	for _, fields := range meta.Unique {
+		idx, err := d.buildUnique(collection, fields)
+		if err != nil {
+			return err
+		}
+		if err := d.saveUnique(collection, fields, idx); err != nil {
+			return err
+		}
+	}
+	for _, field := range meta.Sorted {
+		idx, err := d.buildSorted(collection, field)
+		if err != nil {
+			return err
+		}
+		if err := d.saveSorted(collection, field, idx); err != nil {
+			return err
+		}
+	}
+	d.log.Info("Reindexed '%s'", collection)
+	return nil
+}
+
+// buildIndexes must be called with the collection lock held.
+func (d *Driver) buildIndexes(collection string, fields []string) (map[string]index, error) {
+	indexes := make(map[string]index)
+	for _, field := range fields {
+		indexes[field] = make(index)
+	}
+
+	names, err := d.list(collection)
+	if os.IsNotExist(err) {
+		return indexes, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i, name := range names {
+		if i > 0 && i%1000 == 0 {
+			d.log.Info("Indexed %d/%d records of '%s'", i, len(names), collection)
+		}
+
+		b, err := d.read(collection, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, field := range fields {
+			if key, ok := indexKey(b, field); ok {
+				indexes[field][key] = append(indexes[field][key], name)
+			}
+		}
+	}
+	return indexes, nil
+}
+
+// updateIndexes re-files resource in every registered index of collection.
+// A nil b removes it. It must be called with the collection lock held.
+func (d *Driver) updateIndexes(collection, resource string, b []byte) error {
+	for _, field := range d.meta(collection).Indexes {
+		idx, err := d.loadIndex(collection, field)
+		if err != nil {
+			return err
+		}
+
+		idx.remove(resource)
+		if key, ok := indexKey(b, field); ok {
+			idx.add(key, resource)
+		}
+
+		if err := d.saveIndex(collection, field, idx); err != nil {
+			return err
+		}
+	}
+	return d.updateSorted(collection, resource, b)
+}
+
+func (d *Driver) hasIndex(collection, field string) bool {
+	for _, f := range d.meta(collection).Indexes {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Driver) loadIndex(collection, field string) (index, error) {
+	idx := make(index)
+	b, err := os.ReadFile(indexPath(d.collectionDir(collection), field))
+	if os.IsNotExist(err) {
+		return idx, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &idx); err != nil {
+		return nil, fmt.Errorf("invalid index %v.%v: %v", collection, field, err)
+	}
+	return idx, nil
+}
+
+func (d *Driver) saveIndex(collection, field string, idx index) error {
+	b, err := json.Marshal(idx)
+	if err != nil {
+		return err
+	}
+	return d.writeFile(indexPath(d.collectionDir(collection), field), append(b, byte('\n')))
+}
+
+func indexPath(dir, field string) string {
+	return filepath.Join(dir, ".index."+field+".json")
+}
+
+func (idx index) add(key, resource string) {
+	names := idx[key]
+	i := sort.SearchStrings(names, resource)
+	if i < len(names) && names[i] == resource {
+		return
+	}
+	names = append(names, "")
+	copy(names[i+1:], names[i:])
+	names[i] = resource
+	idx[key] = names
+}
+
+func (idx index) remove(resource string) {
+	for key, names := range idx {
+		i := sort.SearchStrings(names, resource)
+		if i == len(names) || names[i] != resource {
+			continue
+		}
+		names = append(names[:i], names[i+1:]...)
+		if len(names) == 0 {
+			delete(idx, key)
+		} else {
+			idx[key] = names
+		}
+	}
+}
+
+// indexKey returns the index key for the top-level field of the JSON object
+// b: strings are used unquoted, any other value as its compact JSON text.
+func indexKey(b []byte, field string) (string, bool) {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return "", false
+	}
+
+	raw, ok := obj[field]
+	if !ok {
+		return "", false
+	}
+	return rawKey(raw), true
+}
+
+func rawKey(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	return strings.TrimSpace(string(raw))
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"iter"
+)
+
+// Records yields the name and decoded value of each record in collection,
+// in name order, reading one file per step. Iteration ends silently at the
+// first error; use RecordsWithError to find out about it.
+func Records[T any](d *Driver, collection string) iter.Seq2[string, T] {
+	seq, _ := RecordsWithError[T](d, collection)
+	return seq
+}
+
+// RecordsWithError is Records plus a function reporting the error that
+// ended the last iteration, if any. The names are listed under the
+// collection read lock when iteration starts; the records are then read
+// without it, so the loop body may modify the collection, and records
+// deleted in the meantime are skipped.
+func RecordsWithError[T any](d *Driver, collection string) (iter.Seq2[string, T], func() error) {
+	var err error
+	seq := func(yield func(string, T) bool) {
+		err = nil
+		if collection == "" {
+			err = fmt.Errorf("%w - unable to read", ErrMissingCollection)
+			return
+		}
+		if err = validNames(collection); err != nil {
+			return
+		}
+
+		mutex := d.getOrCreateMutex(collection)
+		mutex.RLock()
+		names, lerr := d.list(collection)
+		mutex.RUnlock()
+		if lerr != nil {
+			err = lerr
+			return
+		}
+
+		for _, name := range names {
+			b, rerr := d.read(collection, name)
+			if errors.Is(rerr, ErrNotFound) {
+				continue
+			}
+			if rerr != nil {
+				err = rerr
+				return
+			}
+
+			var v T
+			if derr := d.decode(collection, name, b, &v); derr != nil {
+				err = fmt.Errorf("unable to decode %v: %v", name, derr)
+				return
+			}
+			if !yield(name, v) {
+				return
+			}
+		}
+	}
+	return seq, func() error { return err }
+}
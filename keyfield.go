@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// injectKey adds field:resource to the compact JSON object b. An existing
+// field is accepted only if it already holds resource.
+func injectKey(b []byte, field, resource string) ([]byte, error) {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return nil, fmt.Errorf("key field %v requires records to be JSON objects: %v", field, err)
+	}
+
+	if raw, ok := obj[field]; ok {
+		var existing string
+		if err := json.Unmarshal(raw, &existing); err != nil || existing != resource {
+			return nil, fmt.Errorf("key field %v is %s, conflicts with resource %q", field, raw, resource)
+		}
+		return b, nil
+	}
+
+	k, _ := json.Marshal(field)
+	v, _ := json.Marshal(resource)
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	buf.Write(k)
+	buf.WriteByte(':')
+	buf.Write(v)
+	if len(obj) > 0 {
+		buf.WriteByte(',')
+	}
+	buf.Write(bytes.TrimSpace(b)[1:])
+	return buf.Bytes(), nil
+}
+
+// stripKey checks that the key field of record b, if present, names
+// resource, and returns b without it.
+func stripKey(b []byte, field, resource string) ([]byte, error) {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return b, nil
+	}
+
+	raw, ok := obj[field]
+	if !ok {
+		return b, nil
+	}
+
+	var existing string
+	if err := json.Unmarshal(raw, &existing); err != nil || existing != resource {
+		return nil, fmt.Errorf("record %v has key field %v set to %s", resource, field, raw)
+	}
+
+	delete(obj, field)
+	return json.Marshal(obj)
+}
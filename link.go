@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LinkCollection stores collection in targetDir, e.g. on another disk, by
+// making the collection directory a symlink to it. targetDir is created if
+// needed and may already hold records. The collection must not exist yet,
+// and targetDir must neither be inside the database directory nor contain
+// it, which would make the link a cycle. Deleting a linked collection
+// removes the link but leaves targetDir and its contents in place.
+func (d *Driver) LinkCollection(collection, targetDir string) error {
+	if collection == "" {
+		return fmt.Errorf("%w - unable to link", ErrMissingCollection)
+	}
+	if err := validNames(collection); err != nil {
+		return err
+	}
+	if targetDir == "" {
+		return fmt.Errorf("missing target - unable to link %v", collection)
+	}
+	if d.opts.ReadOnly {
+		return ErrReadOnly
+	}
+
+	if err := d.begin(); err != nil {
+		return err
+	}
+	defer d.end()
+
+	mutex := d.getOrCreateMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	link := d.collectionDir(collection)
+	if _, err := os.Lstat(link); err == nil {
+		return fmt.Errorf("collection %v already exists - unable to link", collection)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	// Check the requested path before creating it, then its resolved form
+	// in case a parent directory is itself a symlink.
+	if err := d.checkLink(collection, targetDir, filepath.Abs); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(targetDir, d.dirMode); err != nil {
+		return err
+	}
+	if err := d.checkLink(collection, targetDir, realPath); err != nil {
+		return err
+	}
+	target, err := realPath(targetDir)
+	if err != nil {
+		return err
+	}
+
+	d.log.Debug("Linking collection '%s' to %s", collection, target)
+	return os.Symlink(target, link)
+}
+
+func (d *Driver) checkLink(collection, targetDir string, resolve func(string) (string, error)) error {
+	target, err := resolve(targetDir)
+	if err != nil {
+		return err
+	}
+	root, err := realPath(d.dir)
+	if err != nil {
+		return err
+	}
+	if within(root, target) || within(target, root) {
+		return fmt.Errorf("unable to link %v to %v: it would form a cycle with %v", collection, target, root)
+	}
+	return nil
+}
+
+// isDir reports whether entry, read from dir, is a directory or a symlink
+// to one, such as a collection set up with LinkCollection.
+func isDir(dir string, entry fs.DirEntry) bool {
+	if entry.IsDir() {
+		return true
+	}
+	if entry.Type()&fs.ModeSymlink == 0 {
+		return false
+	}
+	info, err := os.Stat(filepath.Join(dir, entry.Name()))
+	return err == nil && info.IsDir()
+}
+
+func realPath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(abs)
+}
+
+// within reports whether path is dir or inside it.
+func within(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
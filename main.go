@@ -1,17 +1,26 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math/rand"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/jcelliott/lumber"
 )
 
 const Version = "1.0.0"
 
+const defaultBufferSize = 32 << 10
+
 type Logger interface {
 	Fatal(string, ...interface{})
 	Error(string, ...interface{})
@@ -23,13 +32,241 @@ type Logger interface {
 
 type Driver struct {
 	mutex   sync.Mutex
-	mutexes map[string]*sync.Mutex
+	mutexes map[string]*collectionLock
 	dir     string
 	log     Logger
+	metas   map[string]collectionMeta
+	opts    Options
+
+	onCorrupt func(collection, resource string, raw []byte, err error) ([]byte, error)
+	now       func() time.Time
+	keyField  string
+	fold      func(string) string
+	fallback  *Driver
+	populate  bool
+	through   bool
+	policy    WritePolicy
+	dirMode   os.FileMode
+	fileMode  os.FileMode
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+
+	useWAL     bool
+	walMutex   sync.Mutex
+	wal        *os.File
+	walNext    int64
+	walPending int
+
+	seqMutex sync.Mutex
+
+	rngMutex sync.Mutex
+	rng      *rand.Rand
+
+	opsMutex  sync.Mutex
+	opsIdle   *sync.Cond
+	opsActive int
+	closing   bool
+
+	types   map[string]reflect.Type
+	buckets map[string]*bucket
+
+	unsynced []string
+
+	failAfterTempWrite func(path string) error
 }
 
+// WritePolicy decides what Write does when the record already exists.
+type WritePolicy int
+
+const (
+	// Overwrite replaces the existing record.
+	Overwrite WritePolicy = iota
+	// FailIfExists returns ErrAlreadyExists.
+	FailIfExists
+	// SkipIfExists leaves the existing record untouched and returns nil.
+	SkipIfExists
+)
+
 type Options struct {
 	Logger
+
+	// LogLevel sets the level of the default console logger used when
+	// Logger is nil, e.g. lumber.WARN; it is ignored otherwise. The zero
+	// value, lumber.TRACE, keeps the default of lumber.INFO, so TRACE
+	// logging needs a Logger of its own.
+	LogLevel int
+
+	// OnCorrupt is called by Read when a record fails to unmarshal. It may
+	// return repaired bytes to retry with, or an error to return instead.
+	OnCorrupt func(collection, resource string, raw []byte, err error) ([]byte, error)
+
+	// Clock is used wherever the driver needs the current time. It defaults
+	// to time.Now; tests can set it to get deterministic timestamps.
+	Clock func() time.Time
+
+	// KeyField, when set, makes Write store the resource name in this
+	// top-level field of each record so that records returned by ReadAll
+	// know their own key. Read checks that the field matches and strips it
+	// before decoding.
+	KeyField string
+
+	// Fallback is a second Driver consulted by Read when a record is
+	// missing locally. See fallback.go for the consistency model.
+	Fallback *Driver
+	// PopulateFromFallback copies records read from Fallback into the
+	// local store.
+	PopulateFromFallback bool
+	// WriteThrough repeats every Write on Fallback.
+	WriteThrough bool
+
+	// CaseInsensitive lower-cases collection and resource names before
+	// using them, so "John" and "john" are the same record on every
+	// platform. Records written with mixed-case names while it was off are
+	// not renamed: on case-sensitive filesystems they become unreachable
+	// until they are renamed to their lower-case form.
+	CaseInsensitive bool
+
+	// WritePolicy controls whether Write may replace an existing record.
+	WritePolicy WritePolicy
+
+	// AutoCompactInterval, when positive, starts a background worker that
+	// vacuums and compacts every collection at this interval until Close.
+	AutoCompactInterval time.Duration
+
+	// WAL enables the write-ahead log: every write and delete is appended
+	// to a log in the database directory before it is applied, and New
+	// replays whatever a crash left unapplied.
+	WAL bool
+
+	// DirMode and FileMode are the permissions used for created directories
+	// and files, 0755 and 0644 by default. RespectUmask changes those
+	// defaults to 0777 and 0666 so that the process umask alone decides.
+	// Either way the kernel applies the umask on top, so it can only remove
+	// permission bits from an explicit DirMode or FileMode, never add them.
+	DirMode      os.FileMode
+	FileMode     os.FileMode
+	RespectUmask bool
+
+	// HTTPWrites lets the handler returned by HTTPHandler accept PUT and
+	// DELETE requests. It is read-only otherwise.
+	HTTPWrites bool
+
+	// StaleTmp decides how Read treats a leftover temp file that is newer
+	// than the record it belongs to. By default it is ignored.
+	StaleTmp StaleTmpPolicy
+
+	// OperationTimeout, when positive, bounds how long Write, Read and
+	// Delete may take, including waiting for the collection lock; past it
+	// they return context.DeadlineExceeded. An operation that times out
+	// after it started touching the disk may still complete in the
+	// background, but one still waiting for its lock never will.
+	OperationTimeout time.Duration
+
+	// MaxRecordsPerCollection, when positive, makes Write and Insert fail
+	// with ErrQuotaExceeded rather than add a record to a collection that
+	// already holds this many. Updating an existing record is allowed.
+	MaxRecordsPerCollection int
+
+	// RateLimit, when positive, limits Write, Read and Delete to this many
+	// operations per second on each collection, with bursts of up to
+	// RateLimitBurst (by default the rate itself, at least 1). Operations
+	// over the limit wait or fail according to RateLimitMode.
+	RateLimit      float64
+	RateLimitBurst int
+	RateLimitMode  RateLimitMode
+
+	// Canonicalize sorts the keys of every JSON object before a record is
+	// written, so that the same logical record always produces the same
+	// bytes whatever the order of the struct fields or map it came from.
+	// WriteRaw stores its bytes as given.
+	Canonicalize bool
+
+	// Sharding, when positive, spreads the records of each collection over
+	// this many levels of subdirectories, to keep directories small in very
+	// large collections. See sharding.go for the layout and for migrating
+	// existing collections.
+	Sharding int
+
+	// SingleFilePerCollection stores each collection as a single JSON
+	// object file instead of a directory of record files. See single.go
+	// for the trade-off and the features it supports.
+	SingleFilePerCollection bool
+
+	// UseMmap makes Read decode records straight from a read-only memory
+	// mapping of their file instead of copying them into memory first. It
+	// is ignored when OperationTimeout is set, and by ReadRaw, which has to
+	// return a copy anyway. Platforms without mmap read files normally.
+	UseMmap bool
+
+	// ReadBufferSize and WriteBufferSize size the buffers used when
+	// streaming data rather than handling whole records: blobs, ReadField,
+	// DumpJSON, LoadJSON, FilterToNDJSON and CloneTo. Both default to 32 KiB.
+	ReadBufferSize  int
+	WriteBufferSize int
+
+	// SkipMigrationErrors makes Migrate log and skip records its migration
+	// function fails on instead of stopping at the first one.
+	SkipMigrationErrors bool
+
+	// KeepVersions, when positive, makes every write archive the contents
+	// it replaces under the collection's .versions directory, keeping the
+	// newest KeepVersions per record. See History and ReadVersion.
+	KeepVersions int
+
+	// Checksum stores a SHA-256 of every record as it is written and makes
+	// reads fail with ErrChecksumMismatch if the record changed on disk
+	// since. See checksum.go.
+	Checksum bool
+
+	// SyncEvery, when positive, fsyncs the records written so far and
+	// their directories after every SyncEvery writes, and on Close.
+	// Records are not fsynced otherwise, so durability is left to the
+	// operating system.
+	SyncEvery int
+
+	// FileLocking makes writes and deletes also take an advisory OS lock
+	// per collection, for databases shared by several processes. See
+	// filelock.go for the platforms supporting it.
+	FileLocking bool
+
+	// ReplicaDirs are directories every written record is mirrored to,
+	// best effort, for read-only followers. See replica.go.
+	ReplicaDirs []string
+
+	// RequireExistingCollection makes writes to a collection that doesn't
+	// exist on disk fail with ErrMissingCollection instead of creating it,
+	// so collections have to be made with CreateCollection.
+	RequireExistingCollection bool
+
+	// IDGenerator returns the resource names Insert uses for new records,
+	// e.g. ULIDs or sequential ids. The default is random UUIDs.
+	IDGenerator func() string
+
+	// MinFreeBytes, when positive, makes writes fail with
+	// ErrInsufficientSpace instead of leaving less than this many bytes
+	// free on disk. See space.go.
+	MinFreeBytes int64
+
+	// SampleSeed seeds the random choices of Sample, for reproducible
+	// samples in tests. Zero seeds it from Clock.
+	SampleSeed int64
+
+	// EmptyAsNotFound makes reads treat an empty record file like a missing
+	// record: Read fails with an error matching both ErrEmptyRecord and
+	// ErrNotFound, and ReadAll skips it.
+	EmptyAsNotFound bool
+
+	// ReadOnly opens an existing database for reading only: New neither
+	// creates the directory nor replays the WAL, and every operation that
+	// would modify it fails with ErrReadOnly.
+	ReadOnly bool
+
+	// failAfterTempWrite is a test-only hook called once a record's new
+	// contents are fully written but before they replace the old file. A
+	// non-nil error aborts the write at that point, simulating a crash.
+	failAfterTempWrite func(path string) error
 }
 
 func New(dir string, options *Options) (*Driver, error) {
@@ -39,155 +276,1144 @@ func New(dir string, options *Options) (*Driver, error) {
 	if options != nil {
 		opts = *options
 	}
-	if opts.Logger == nil {
-		opts.Logger = lumber.NewConsoleLogger(lumber.INFO)
+	if isNil(opts.Logger) {
+		level := lumber.INFO
+		if opts.LogLevel != lumber.TRACE {
+			level = opts.LogLevel
+		}
+		opts.Logger = lumber.NewConsoleLogger(level)
+	}
+	if opts.Clock == nil {
+		opts.Clock = time.Now
+	}
+
+	if opts.DirMode == 0 {
+		opts.DirMode = 0755
+		if opts.RespectUmask {
+			opts.DirMode = 0777
+		}
+	}
+	if opts.FileMode == 0 {
+		opts.FileMode = 0644
+		if opts.RespectUmask {
+			opts.FileMode = 0666
+		}
+	}
+
+	if opts.ReadBufferSize <= 0 {
+		opts.ReadBufferSize = defaultBufferSize
+	}
+	if opts.WriteBufferSize <= 0 {
+		opts.WriteBufferSize = defaultBufferSize
+	}
+
+	fold := func(name string) string { return name }
+	if opts.CaseInsensitive {
+		fold = strings.ToLower
 	}
 
 	driver := Driver{
 		dir:     dir,
-		mutexes: make(map[string]*sync.Mutex),
+		mutexes: make(map[string]*collectionLock),
 		log:     opts.Logger,
+		metas:   make(map[string]collectionMeta),
+
+		onCorrupt: opts.OnCorrupt,
+		now:       opts.Clock,
+		keyField:  opts.KeyField,
+		fold:      fold,
+		opts:      opts,
+		fallback:  opts.Fallback,
+		populate:  opts.PopulateFromFallback,
+		through:   opts.WriteThrough,
+		policy:    opts.WritePolicy,
+		dirMode:   opts.DirMode,
+		fileMode:  opts.FileMode,
+		useWAL:    opts.WAL,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+
+		failAfterTempWrite: opts.failAfterTempWrite,
+	}
+	driver.opsIdle = sync.NewCond(&driver.opsMutex)
+
+	seed := opts.SampleSeed
+	if seed == 0 {
+		seed = opts.Clock().UnixNano()
 	}
+	driver.rng = rand.New(rand.NewSource(seed))
 
 	if _, err := os.Stat(dir); err == nil {
 		opts.Logger.Debug("Using '%s' (database already exists)\n", dir)
-		return &driver, nil
+		if err := driver.loadMetas(); err != nil {
+			return &driver, err
+		}
+	} else if opts.ReadOnly {
+		return &driver, err
+	} else {
+		opts.Logger.Debug("Creating the database at '%s' ...\n", dir)
+		if err := os.MkdirAll(dir, driver.dirMode); err != nil {
+			return &driver, err
+		}
+	}
+	if !opts.ReadOnly {
+		for _, replica := range opts.ReplicaDirs {
+			if err := os.MkdirAll(replica, driver.dirMode); err != nil {
+				return &driver, err
+			}
+		}
+	}
+
+	if opts.WAL && !opts.ReadOnly {
+		if err := driver.openWAL(); err != nil {
+			return &driver, err
+		}
 	}
 
-	opts.Logger.Debug("Creating the database at '%s' ...\n", dir)
-	return &driver, os.MkdirAll(dir, 0755)
+	if opts.AutoCompactInterval > 0 && !opts.ReadOnly {
+		go driver.autoCompact(opts.AutoCompactInterval)
+	} else {
+		close(driver.done)
+	}
+	return &driver, nil
+}
+
+// Close stops the background workers started by New and waits for them to
+// exit, then makes new operations fail with ErrClosed and waits for the
+// ones in flight. It is safe to call more than once.
+func (d *Driver) Close() error {
+	d.closeOnce.Do(func() { close(d.stop) })
+	<-d.done
+	d.shutdown()
+	if err := d.syncWrites(); err != nil {
+		return err
+	}
+	return d.closeWAL()
 }
 func (d *Driver) Write(collection, resource string, v interface{}) error {
 	if collection == "" {
-		return fmt.Errorf("missing collection - no place to save records")
+		return fmt.Errorf("%w - no place to save records", ErrMissingCollection)
 	}
 	if resource == "" {
-		return fmt.Errorf("missing resource - unable to save record (no name)!")
+		return fmt.Errorf("%w - unable to save record (no name)!", ErrMissingResource)
+	}
+	if err := validNames(collection, resource); err != nil {
+		return err
 	}
 
-	mutex := d.getOrCreateMutex(collection)
-	mutex.Lock()
-	defer mutex.Unlock()
+	b, err := d.marshal(collection, resource, v)
+	if err != nil {
+		d.log.Error("JSON Marshalling failed: %v", err)
+		return err
+	}
+
+	return d.withTimeout(func(ctx context.Context) error {
+		if err := d.limit(ctx, collection); err != nil {
+			return err
+		}
+
+		mutex := d.getOrCreateMutex(collection)
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return d.put(collection, resource, b)
+	})
+}
+
+// WriteIfNotExists writes v only if collection/resource does not exist yet,
+// checking under the collection lock so that concurrent callers cannot both
+// create it, and reports whether it wrote.
+func (d *Driver) WriteIfNotExists(collection, resource string, v interface{}) (bool, error) {
+	if collection == "" {
+		return false, fmt.Errorf("%w - no place to save records", ErrMissingCollection)
+	}
+	if resource == "" {
+		return false, fmt.Errorf("%w - unable to save record (no name)!", ErrMissingResource)
+	}
+	if err := validNames(collection, resource); err != nil {
+		return false, err
+	}
+
+	b, err := d.marshal(collection, resource, v)
+	if err != nil {
+		d.log.Error("JSON Marshalling failed: %v", err)
+		return false, err
+	}
+
+	wrote := false
+	err = d.withTimeout(func(ctx context.Context) error {
+		mutex := d.getOrCreateMutex(collection)
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.exists(collection, resource) {
+			return nil
+		}
+		if err := d.put(collection, resource, b); err != nil {
+			return err
+		}
+		wrote = true
+		return nil
+	})
+	return wrote, err
+}
+
+// put applies the write policy and stores the marshalled record b. It must
+// be called with the collection lock held.
+func (d *Driver) put(collection, resource string, b []byte) error {
+	exists := d.exists(collection, resource)
+	if d.policy != Overwrite && exists {
+		if d.policy == SkipIfExists {
+			d.log.Debug("Skipping existing record: %s/%s", collection, resource)
+			return nil
+		}
+		return fmt.Errorf("%w: %v/%v", ErrAlreadyExists, collection, resource)
+	}
+
+	if max := d.opts.MaxRecordsPerCollection; max > 0 && !exists {
+		names, err := d.list(collection)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if len(names) >= max {
+			return fmt.Errorf("%w: %v has %d records", ErrQuotaExceeded, collection, len(names))
+		}
+	}
+
+	if err := d.write(collection, resource, b); err != nil {
+		return err
+	}
+
+	if d.fallback != nil && d.through {
+		return d.fallback.Write(collection, resource, json.RawMessage(b))
+	}
+	return nil
+}
+
+// write must be called with the collection lock held.
+func (d *Driver) write(collection, resource string, b []byte) error {
+	if d.opts.ReadOnly {
+		return ErrReadOnly
+	}
+	if err := d.begin(); err != nil {
+		return err
+	}
+	defer d.end()
 
-	dir := filepath.Join(d.dir, collection)
-	finalPath := filepath.Join(dir, resource+".json")
-	tmpPath := finalPath + ".tmp"
+	unlock, err := d.lockFile(collection)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if d.opts.RequireExistingCollection && !d.collectionExists(collection) {
+		return fmt.Errorf("%w - collection %v does not exist", ErrMissingCollection, collection)
+	}
+	if d.useWAL {
+		id, err := d.logWAL(walEntry{Op: walWrite, Collection: collection, Resource: resource, Data: b})
+		if err != nil {
+			return err
+		}
+		defer d.checkpoint(id)
+	}
+	return d.applyWrite(collection, resource, b)
+}
+
+func (d *Driver) applyWrite(collection, resource string, b []byte) error {
+	if d.opts.SingleFilePerCollection {
+		return d.writeSingle(collection, resource, b)
+	}
+	finalPath := d.recordPath(collection, resource) + ".json"
+	dir := filepath.Dir(finalPath)
 
 	d.log.Debug("Creating directory: %s", dir)
-	if err := os.MkdirAll(dir, 0755); err != nil {
+	if err := os.MkdirAll(dir, d.dirMode); err != nil {
 		d.log.Error("Failed to create directory: %v", err)
 		return err
 	}
+	if err := d.checkSpace(dir, len(b)); err != nil {
+		return err
+	}
 
-	b, err := json.MarshalIndent(v, "", "\t")
-	if err != nil {
-		d.log.Error("JSON Marshalling failed: %v", err)
+	if err := d.checkUnique(collection, d.fold(resource), b); err != nil {
 		return err
 	}
-	b = append(b, byte('\n'))
+	if d.opts.KeepVersions > 0 {
+		if err := d.archive(collection, resource, b); err != nil {
+			return err
+		}
+	}
 
-	d.log.Debug("Writing to temp file: %s", tmpPath)
-	if err := os.WriteFile(tmpPath, b, 0644); err != nil {
-		d.log.Error("Failed to write temp file: %v", err)
+	d.log.Debug("Writing record: %s", finalPath)
+	if err := d.writeFile(finalPath, b); err != nil {
+		d.log.Error("Failed to write record: %v", err)
+		return err
+	}
+	if err := d.noteWrite(finalPath); err != nil {
+		return err
+	}
+	d.mirror(finalPath, b)
+	if d.opts.Checksum {
+		if err := d.saveChecksum(collection, resource, b); err != nil {
+			return err
+		}
+	}
+	if err := d.updateIndexes(collection, d.fold(resource), b); err != nil {
 		return err
 	}
+	return d.updateUnique(collection, d.fold(resource), b)
+}
+
+func (d *Driver) marshal(collection, resource string, v interface{}) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.keyField != "" {
+		if b, err = injectKey(b, d.keyField, d.fold(resource)); err != nil {
+			return nil, err
+		}
+	}
+	if d.opts.Canonicalize {
+		if b, err = canonicalize(b); err != nil {
+			return nil, err
+		}
+	}
+
+	cfg := d.config(collection)
+	if !cfg.Compact {
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, b, "", "\t"); err != nil {
+			return nil, err
+		}
+		b = buf.Bytes()
+	}
+	if cfg.OmitNewline {
+		return b, nil
+	}
+	return append(b, byte('\n')), nil
+}
 
-	d.log.Debug("Renaming temp file to final: %s", finalPath)
-	return os.Rename(tmpPath, finalPath)
+// collectionDir returns the directory holding collection.
+func (d *Driver) collectionDir(collection string) string {
+	return filepath.Join(d.dir, d.fold(collection))
+}
+
+// recordPath returns the path of a record, without its file extension.
+func (d *Driver) recordPath(collection, resource string) string {
+	resource = d.fold(resource)
+	return filepath.Join(d.shardDir(d.collectionDir(collection), resource), resource)
+}
+
+// writeFileRename atomically replaces path with b by writing a temp file
+// next to it and renaming it into place. It backs writeFile on platforms
+// without a faster alternative.
+func (d *Driver) writeFileRename(path string, b []byte) error {
+	if d.opts.ReadOnly {
+		return ErrReadOnly
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, b, d.fileMode); err != nil {
+		return err
+	}
+	if d.failAfterTempWrite != nil {
+		if err := d.failAfterTempWrite(path); err != nil {
+			return err
+		}
+	}
+	return os.Rename(tmpPath, path)
 }
 
 
 func (d *Driver) Read(collection, resource string, v interface{}) error {
 	if collection == "" {
-		return fmt.Errorf("missing collection - unable to read")
+		return fmt.Errorf("%w - unable to read", ErrMissingCollection)
 	}
 	if resource == "" {
-		return fmt.Errorf("missing resource - unable to read (no name)")
+		return fmt.Errorf("%w - unable to read (no name)", ErrMissingResource)
+	}
+	if err := validNames(collection, resource); err != nil {
+		return err
+	}
+	if err := checkTarget(v); err != nil {
+		return err
 	}
 
-	record := filepath.Join(d.dir, collection, resource)
+	if d.opts.UseMmap && d.opts.OperationTimeout <= 0 && !d.opts.SingleFilePerCollection {
+		if err := d.begin(); err != nil {
+			return err
+		}
+		if err := d.limit(context.Background(), collection); err != nil {
+			d.end()
+			return err
+		}
+		err := d.readMapped(collection, resource, v)
+		d.end()
+		if d.fallback == nil || !errors.Is(err, ErrNotFound) {
+			return err
+		}
+	}
 
-	if _, err := stat(record); err != nil {
+	var b []byte
+	err := d.withTimeout(func(ctx context.Context) error {
+		if err := d.limit(ctx, collection); err != nil {
+			return err
+		}
+
+		var err error
+		b, err = d.readThrough(collection, resource)
+		return err
+	})
+	if err != nil {
 		return err
 	}
+	return d.decode(collection, resource, b, v)
+}
 
-	b, err := os.ReadFile(record + ".json")
+// ReadFresh is Read that also reports whether the record was modified within
+// maxAge, judged by its file's modification time. A stale record is still
+// decoded into v. Records served from Fallback without a local copy are
+// reported as stale.
+func (d *Driver) ReadFresh(collection, resource string, maxAge time.Duration, v interface{}) (bool, error) {
+	if err := d.Read(collection, resource, v); err != nil {
+		return false, err
+	}
+
+	fi, err := os.Stat(d.recordPath(collection, resource) + ".json")
+	if os.IsNotExist(err) && d.fallback != nil {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return d.now().Sub(fi.ModTime()) <= maxAge, nil
+}
+
+// checkEmpty returns ErrEmptyRecord, or with Options.EmptyAsNotFound also
+// ErrNotFound, if the stored bytes b of a record hold nothing but
+// whitespace, as a crash or an external edit may leave behind.
+func (d *Driver) checkEmpty(collection, resource string, b []byte) error {
+	if len(bytes.TrimSpace(b)) > 0 {
+		return nil
+	}
+	if d.opts.EmptyAsNotFound {
+		return fmt.Errorf("%w: %w: %v/%v", ErrNotFound, ErrEmptyRecord, collection, resource)
+	}
+	return fmt.Errorf("%w: %v/%v", ErrEmptyRecord, collection, resource)
+}
+
+// checkTarget reports whether v can be decoded into, so that reads fail up
+// front rather than with json.Unmarshal's error after reading the record.
+func checkTarget(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return ErrInvalidTarget
+	}
+	return nil
+}
+
+// decode unmarshals the stored bytes b of a record into v.
+func (d *Driver) decode(collection, resource string, b []byte, v interface{}) error {
+	var err error
+	if d.keyField != "" {
+		if b, err = stripKey(b, d.keyField, d.fold(resource)); err != nil {
+			return err
+		}
+	}
+
+	err = json.Unmarshal(b, v)
+	if err == nil || d.onCorrupt == nil {
+		return err
+	}
+
+	d.log.Warn("Corrupt record %s/%s: %v", collection, resource, err)
+	b, err = d.onCorrupt(collection, resource, bytes.Clone(b), err)
 	if err != nil {
 		return err
 	}
 	return json.Unmarshal(b, v)
 }
 
+// withTimeout runs op, giving up after OperationTimeout. op must check its
+// context once it holds its locks so that abandoned work is not applied.
+func (d *Driver) withTimeout(op func(ctx context.Context) error) error {
+	if err := d.begin(); err != nil {
+		return err
+	}
+	if d.opts.OperationTimeout <= 0 {
+		defer d.end()
+		return op(context.Background())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), d.opts.OperationTimeout)
+	defer cancel()
+
+	errc := make(chan error, 1)
+	go func() {
+		defer d.end()
+		errc <- op(ctx)
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (d *Driver) exists(collection, resource string) bool {
+	if d.opts.SingleFilePerCollection {
+		_, err := d.readSingle(collection, resource)
+		return err == nil
+	}
+	_, err := os.Stat(d.recordPath(collection, resource) + ".json")
+	return err == nil
+}
+
+func (d *Driver) read(collection, resource string) ([]byte, error) {
+	if err := d.begin(); err != nil {
+		return nil, err
+	}
+	defer d.end()
+
+	if d.opts.SingleFilePerCollection {
+		return d.readSingle(collection, resource)
+	}
+	record := d.recordPath(collection, resource)
+
+	if _, err := stat(record); err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: %w", ErrNotFound, err)
+		}
+		return nil, err
+	}
+
+	b, err := os.ReadFile(record + ".json")
+	if err == nil {
+		err = d.checkEmpty(collection, resource, b)
+	}
+	if err != nil || !d.opts.Checksum {
+		return b, err
+	}
+
+	// Reads don't take the collection lock, so a write may land between
+	// reading the record and its checksum. Retry once before reporting it.
+	if err := d.verifyChecksum(collection, resource, b); err == nil || !errors.Is(err, ErrChecksumMismatch) {
+		return b, err
+	}
+	if b, err = os.ReadFile(record + ".json"); err != nil {
+		return nil, err
+	}
+	return b, d.verifyChecksum(collection, resource, b)
+}
+
+// ReadRaw returns the stored bytes of a record without decoding them. It
+// never takes the collection lock, so it is safe under LockCollection, and
+// for the same reason it doesn't populate the local store from Fallback.
+func (d *Driver) ReadRaw(collection, resource string) ([]byte, error) {
+	if collection == "" {
+		return nil, fmt.Errorf("%w - unable to read", ErrMissingCollection)
+	}
+	if resource == "" {
+		return nil, fmt.Errorf("%w - unable to read (no name)", ErrMissingResource)
+	}
+	if err := validNames(collection, resource); err != nil {
+		return nil, err
+	}
+	return d.readLocked(collection, resource)
+}
+
+// WriteRaw stores b, which must be valid JSON, as a record. Unlike Write it
+// does not take the collection lock: call it while holding LockCollection.
+func (d *Driver) WriteRaw(collection, resource string, b []byte) error {
+	if collection == "" {
+		return fmt.Errorf("%w - no place to save records", ErrMissingCollection)
+	}
+	if resource == "" {
+		return fmt.Errorf("%w - unable to save record (no name)!", ErrMissingResource)
+	}
+	if err := validNames(collection, resource); err != nil {
+		return err
+	}
+	if !json.Valid(b) {
+		return fmt.Errorf("invalid JSON - unable to save record %v", resource)
+	}
+	return d.write(collection, resource, b)
+}
+
+// LockCollection takes the collection's write lock and returns the function
+// that releases it, letting callers do their own read-modify-write with
+// ReadRaw and WriteRaw. While it is held, any other locking method on the
+// same collection (Write, Delete, List, Count, ReadAll, ...) called from the
+// same goroutine will deadlock, since the lock is not reentrant.
+func (d *Driver) LockCollection(collection string) (unlock func()) {
+	mutex := d.getOrCreateMutex(collection)
+	mutex.Lock()
+	return mutex.Unlock
+}
+
 func (d *Driver) ReadAll(collection string) ([]string, error) {
+	raw, err := d.readAll(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []string
+	for _, b := range raw {
+		records = append(records, string(b))
+	}
+	return records, nil
+}
+
+// ReadAllRawMessages is like ReadAll but returns each record as a
+// json.RawMessage, ready to be decoded selectively or passed through.
+func (d *Driver) ReadAllRawMessages(collection string) ([]json.RawMessage, error) {
+	raw, err := d.readAll(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]json.RawMessage, len(raw))
+	for i, b := range raw {
+		records[i] = b
+	}
+	return records, nil
+}
+
+func (d *Driver) readAll(collection string) ([][]byte, error) {
 	if collection == "" {
-		return nil, fmt.Errorf("missing collection - unable to read")
+		return nil, fmt.Errorf("%w - unable to read", ErrMissingCollection)
+	}
+	if err := validNames(collection); err != nil {
+		return nil, err
 	}
 
-	dir := filepath.Join(d.dir, collection)
-	if _, err := stat(dir); err != nil {
+	if err := d.begin(); err != nil {
 		return nil, err
 	}
+	defer d.end()
+
+	mutex := d.getOrCreateMutex(collection)
+	mutex.RLock()
+	defer mutex.RUnlock()
 
-	files, err := os.ReadDir(dir)
+	names, err := d.list(collection)
+	missing := os.IsNotExist(err)
+	if missing && d.fallback != nil {
+		names, err = nil, nil
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	var records []string
-	for _, file := range files {
-		b, err := os.ReadFile(filepath.Join(dir, file.Name()))
+	read := func(name string) ([]byte, error) {
+		return os.ReadFile(d.recordPath(collection, name) + ".json")
+	}
+	if d.opts.SingleFilePerCollection && len(names) > 0 {
+		single, err := d.loadSingle(collection)
 		if err != nil {
 			return nil, err
 		}
-		records = append(records, string(b))
+		read = func(name string) ([]byte, error) { return single[name], nil }
+	}
+
+	var records [][]byte
+	for _, name := range names {
+		b, err := read(name)
+		if err == nil {
+			err = d.checkEmpty(collection, name, b)
+		}
+		if errors.Is(err, ErrEmptyRecord) && errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, b)
+	}
+
+	if d.fallback != nil {
+		extra, err := d.fallbackOnly(collection, names)
+		if err != nil && (missing || !os.IsNotExist(err)) {
+			return nil, err
+		}
+		records = append(records, extra...)
 	}
 
 	return records, nil
 }
 
-func (d *Driver) Delete(collection, resource string) error {
+// ReadAllPartial is like ReadAll but keeps going past records it can't
+// read, returning those that succeeded along with each failure keyed by
+// resource name. If the collection itself can't be listed, that error is
+// reported under the empty name.
+func (d *Driver) ReadAllPartial(collection string) ([]string, map[string]error) {
+	failed := make(map[string]error)
 	if collection == "" {
-		return fmt.Errorf("missing collection - unable to delete")
+		failed[""] = fmt.Errorf("%w - unable to read", ErrMissingCollection)
+		return nil, failed
+	}
+	if err := validNames(collection); err != nil {
+		failed[""] = err
+		return nil, failed
 	}
 
 	mutex := d.getOrCreateMutex(collection)
-	mutex.Lock()
-	defer mutex.Unlock()
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	names, err := d.list(collection)
+	if err != nil {
+		failed[""] = err
+		return nil, failed
+	}
+
+	var records []string
+	for _, name := range names {
+		b, err := d.read(collection, name)
+		if err != nil {
+			failed[name] = err
+			continue
+		}
+		records = append(records, string(b))
+	}
+	return records, failed
+}
+
+// ReadAllOrEmpty is like ReadAll but treats a collection that doesn't exist
+// as an empty one.
+func (d *Driver) ReadAllOrEmpty(collection string) ([]string, error) {
+	records, err := d.ReadAll(collection)
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	}
+	return records, err
+}
+
+// Collections returns the names of all collections, in sorted order.
+func (d *Driver) Collections() ([]string, error) {
+	if err := d.begin(); err != nil {
+		return nil, err
+	}
+	defer d.end()
+
+	entries, err := os.ReadDir(d.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		if d.opts.SingleFilePerCollection {
+			if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".json") {
+				names = append(names, strings.TrimSuffix(entry.Name(), ".json"))
+			}
+			continue
+		}
+		if isDir(d.dir, entry) {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
+// IsEmpty reports whether the database holds no records or blobs. Empty
+// collection directories, and ones holding only metadata such as a
+// configuration or indexes, count as empty.
+func (d *Driver) IsEmpty() (bool, error) {
+	collections, err := d.Collections()
+	if err != nil {
+		return false, err
+	}
+
+	for _, collection := range collections {
+		if d.opts.SingleFilePerCollection {
+			if n, err := d.Count(collection); err != nil || n > 0 {
+				return false, err
+			}
+			continue
+		}
+
+		mutex := d.getOrCreateMutex(collection)
+		mutex.RLock()
+		entries, err := d.recordEntries(collection)
+		mutex.RUnlock()
+		if err != nil {
+			return false, err
+		}
+
+		for _, entry := range entries {
+			if !entry.IsDir() && (isRecord(entry.Name()) || isBlob(entry.Name())) {
+				return false, nil
+			}
+		}
+	}
+	return true, nil
+}
+
+// CollectionSummaries returns the number of committed records in every
+// collection, reading each collection directory once under its read lock.
+func (d *Driver) CollectionSummaries() (map[string]int, error) {
+	collections, err := d.Collections()
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := make(map[string]int, len(collections))
+	for _, collection := range collections {
+		if d.opts.SingleFilePerCollection {
+			n, err := d.Count(collection)
+			if err != nil {
+				return nil, err
+			}
+			summaries[collection] = n
+			continue
+		}
+
+		mutex := d.getOrCreateMutex(collection)
+		mutex.RLock()
+		entries, err := d.recordEntries(collection)
+		mutex.RUnlock()
+		if err != nil {
+			return nil, err
+		}
+
+		n := 0
+		for _, entry := range entries {
+			if !entry.IsDir() && isRecord(entry.Name()) {
+				n++
+			}
+		}
+		summaries[collection] = n
+	}
+	return summaries, nil
+}
+
+// List returns the names of the records in collection, in sorted order.
+func (d *Driver) List(collection string) ([]string, error) {
+	if collection == "" {
+		return nil, fmt.Errorf("%w - unable to list", ErrMissingCollection)
+	}
+	if err := validNames(collection); err != nil {
+		return nil, err
+	}
+
+	mutex := d.getOrCreateMutex(collection)
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	return d.list(collection)
+}
+
+// Glob returns the names of the records in collection matching the shell
+// pattern, using the syntax of filepath.Match.
+func (d *Driver) Glob(collection, pattern string) ([]string, error) {
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+	}
+
+	names, err := d.List(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []string
+	for _, name := range names {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			matches = append(matches, name)
+		}
+	}
+	return matches, nil
+}
+
+// Count returns the number of committed records in collection. It holds the
+// collection read lock so an in-flight Write is never counted twice.
+func (d *Driver) Count(collection string) (int, error) {
+	if collection == "" {
+		return 0, fmt.Errorf("%w - unable to count", ErrMissingCollection)
+	}
+	if err := validNames(collection); err != nil {
+		return 0, err
+	}
+
+	mutex := d.getOrCreateMutex(collection)
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	names, err := d.list(collection)
+	if err != nil {
+		return 0, err
+	}
+	return len(names), nil
+}
+
+// ApproxCount returns a fast estimate of the number of records in
+// collection. It takes no lock and only skips directories, blobs and hidden
+// metadata files, so a Write in progress may be counted twice (once for its
+// .tmp file). Use Count when the exact number matters.
+func (d *Driver) ApproxCount(collection string) (int, error) {
+	if collection == "" {
+		return 0, fmt.Errorf("%w - unable to count", ErrMissingCollection)
+	}
+	if err := validNames(collection); err != nil {
+		return 0, err
+	}
+
+	if err := d.begin(); err != nil {
+		return 0, err
+	}
+	defer d.end()
+
+	files, err := d.recordEntries(collection)
+	if err != nil {
+		return 0, err
+	}
+
+	n := len(files)
+	for _, file := range files {
+		if file.IsDir() || isBlob(file.Name()) || strings.HasPrefix(file.Name(), ".") {
+			n--
+		}
+	}
+	return n, nil
+}
+
+// list must be called with the collection lock held.
+func (d *Driver) list(collection string) ([]string, error) {
+	if err := d.begin(); err != nil {
+		return nil, err
+	}
+	defer d.end()
+
+	if d.opts.SingleFilePerCollection {
+		return d.listSingle(collection)
+	}
+	dir := d.collectionDir(collection)
+	if _, err := stat(dir); err != nil {
+		return nil, err
+	}
+
+	files, err := d.recordEntries(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, file := range files {
+		if file.IsDir() || !isRecord(file.Name()) {
+			continue
+		}
+		names = append(names, strings.TrimSuffix(file.Name(), ".json"))
+	}
+	return names, nil
+}
+
+func (d *Driver) Delete(collection, resource string) error {
+	if collection == "" {
+		return fmt.Errorf("%w - unable to delete", ErrMissingCollection)
+	}
+	if err := validNames(collection, resource); err != nil {
+		return err
+	}
+
+	return d.withTimeout(func(ctx context.Context) error {
+		if err := d.limit(ctx, collection); err != nil {
+			return err
+		}
+
+		mutex := d.getOrCreateMutex(collection)
+		mutex.Lock()
+		defer mutex.Unlock()
 
-	path := filepath.Join(d.dir, collection, resource)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return d.delete(collection, resource)
+	})
+}
+
+// delete must be called with the collection lock held.
+func (d *Driver) delete(collection, resource string) error {
+	if d.opts.ReadOnly {
+		return ErrReadOnly
+	}
+	if d.opts.SingleFilePerCollection {
+		if resource == "" {
+			if _, err := os.Stat(d.singlePath(collection)); err != nil {
+				return fmt.Errorf("%w: %w", ErrNotFound, err)
+			}
+			return d.removeCollection(collection)
+		}
+		if _, err := d.readSingle(collection, resource); err != nil {
+			return err
+		}
+		return d.remove(collection, resource)
+	}
+	// With Sharding, recordPath of an empty resource is a shard directory
+	// inside the collection, not the collection itself.
+	path := d.collectionDir(collection)
+	if resource != "" {
+		path = d.recordPath(collection, resource)
+	}
 
 	fi, err := stat(path)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%w: %w", ErrNotFound, err)
+	}
 	if err != nil {
-		return fmt.Errorf("unable to find file or directory named %v\n", path)
+		return err
 	}
 
 	if fi.Mode().IsDir() {
+		if resource == "" {
+			return d.removeCollection(collection)
+		}
 		return os.RemoveAll(path)
 	}
 	if fi.Mode().IsRegular() {
-		return os.Remove(path + ".json")
+		return d.remove(collection, resource)
 	}
 	return nil
 }
 
-func (d *Driver) getOrCreateMutex(collection string) *sync.Mutex {
+// remove deletes a single record or blob. It must be called with the
+// collection lock held.
+func (d *Driver) remove(collection, resource string) error {
+	if d.opts.ReadOnly {
+		return ErrReadOnly
+	}
+	if err := d.begin(); err != nil {
+		return err
+	}
+	defer d.end()
+
+	unlock, err := d.lockFile(collection)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if d.useWAL {
+		id, err := d.logWAL(walEntry{Op: walDelete, Collection: collection, Resource: resource})
+		if err != nil {
+			return err
+		}
+		defer d.checkpoint(id)
+	}
+	return d.applyRemove(collection, resource)
+}
+
+// removeCollection deletes a whole collection. It must be called with the
+// collection lock held.
+func (d *Driver) removeCollection(collection string) error {
+	if d.opts.ReadOnly {
+		return ErrReadOnly
+	}
+	if err := d.begin(); err != nil {
+		return err
+	}
+	defer d.end()
+
+	unlock, err := d.lockFile(collection)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if d.useWAL {
+		id, err := d.logWAL(walEntry{Op: walDelete, Collection: collection})
+		if err != nil {
+			return err
+		}
+		defer d.checkpoint(id)
+	}
+	return d.applyRemove(collection, "")
+}
+
+func (d *Driver) applyRemove(collection, resource string) error {
+	if d.opts.SingleFilePerCollection {
+		return d.removeSingle(collection, resource)
+	}
+	if resource == "" {
+		d.mutex.Lock()
+		delete(d.metas, d.fold(collection))
+		d.mutex.Unlock()
+		if err := os.RemoveAll(d.collectionDir(collection)); err != nil {
+			return err
+		}
+		d.unmirror(d.collectionDir(collection))
+		return nil
+	}
+
+	path := d.recordPath(collection, resource)
+	err := os.Remove(path + ".json")
+	if os.IsNotExist(err) {
+		return os.Remove(path + blobExt)
+	}
+	if err != nil {
+		return err
+	}
+	d.unmirror(path + ".json")
+	if err := d.removeChecksum(collection, resource); err != nil {
+		return err
+	}
+	if err := d.updateIndexes(collection, d.fold(resource), nil); err != nil {
+		return err
+	}
+	return d.updateUnique(collection, d.fold(resource), nil)
+}
+
+func (d *Driver) getOrCreateMutex(collection string) *collectionLock {
+	collection = d.fold(collection)
+
 	d.mutex.Lock()
 	defer d.mutex.Unlock()
 	m, ok := d.mutexes[collection]
 
 	if !ok {
-		m = &sync.Mutex{}
+		m = &collectionLock{}
 		d.mutexes[collection] = m
 	}
+	m.users.Add(1)
 
 	return m
 }
 
+// isNil reports whether l is nil, including a typed nil pointer stored in
+// the interface, which would otherwise panic on first use.
+func isNil(l Logger) bool {
+	if l == nil {
+		return true
+	}
+	v := reflect.ValueOf(l)
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan, reflect.Interface:
+		return v.IsNil()
+	}
+	return false
+}
+
 func stat(path string) (os.FileInfo, error) {
 	fi, err := os.Stat(path)
 	if os.IsNotExist(err) {
 		fi, err = os.Stat(path + ".json")
 	}
+	if os.IsNotExist(err) {
+		if bfi, berr := os.Stat(path + blobExt); berr == nil {
+			return bfi, nil
+		}
+	}
 	return fi, err
 }
 
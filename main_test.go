@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jcelliott/lumber"
+)
+
+// newTestDriver opens a database in a fresh temporary directory, with
+// logging limited to fatal errors unless opts sets a level.
+func newTestDriver(t testing.TB, opts *Options) *Driver {
+	t.Helper()
+	return newTestDriverIn(t, filepath.Join(t.TempDir(), "db"), opts)
+}
+
+// newTestDriverIn is newTestDriver for the database in dir.
+func newTestDriverIn(t testing.TB, dir string, opts *Options) *Driver {
+	t.Helper()
+	if opts == nil {
+		opts = &Options{}
+	}
+	if opts.LogLevel == 0 && opts.Logger == nil {
+		opts.LogLevel = lumber.FATAL
+	}
+
+	d, err := New(dir, opts)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { d.Close() })
+	return d
+}
+
+func TestCountWhileWriting(t *testing.T) {
+	d := newTestDriver(t, nil)
+	const records = 200
+
+	// A leftover temp file from an interrupted write is never counted.
+	if err := d.Write("users", "r0", map[string]int{"n": 0}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(d.recordPath("users", "ghost")+".json.tmp", []byte(`{}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var written atomic.Int64
+	written.Store(1)
+	errc := make(chan error, 1)
+	go func() {
+		for i := 1; i < records; i++ {
+			// Overwrite an existing record between new ones, so that
+			// replacing files is exercised as well as creating them.
+			if err := d.Write("users", "r0", map[string]int{"n": i}); err != nil {
+				errc <- err
+				return
+			}
+			if err := d.Write("users", "r"+strconv.Itoa(i), map[string]int{"n": i}); err != nil {
+				errc <- err
+				return
+			}
+			written.Add(1)
+		}
+		errc <- nil
+	}()
+
+	for done := false; !done; {
+		select {
+		case err := <-errc:
+			if err != nil {
+				t.Fatal(err)
+			}
+			done = true
+		default:
+		}
+
+		before := written.Load()
+		n, err := d.Count("users")
+		after := written.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if int64(n) < before || int64(n) > after+1 {
+			t.Fatalf("Count = %d while %d to %d records were committed", n, before, after)
+		}
+
+		names, err := d.List("users")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range names {
+			if strings.Contains(name, ".tmp") || name == "ghost" {
+				t.Fatalf("List returned %q", name)
+			}
+		}
+	}
+
+	if n, err := d.Count("users"); err != nil || n != records {
+		t.Fatalf("Count = %d, %v, want %d", n, err, records)
+	}
+}
+
+// nopLogger counts and discards messages, so calling it through a nil
+// *nopLogger panics.
+type nopLogger struct{ calls atomic.Int64 }
+
+func (l *nopLogger) Fatal(f string, v ...interface{}) { l.calls.Add(1) }
+func (l *nopLogger) Error(f string, v ...interface{}) { l.calls.Add(1) }
+func (l *nopLogger) Warn(f string, v ...interface{})  { l.calls.Add(1) }
+func (l *nopLogger) Info(f string, v ...interface{})  { l.calls.Add(1) }
+func (l *nopLogger) Debug(f string, v ...interface{}) { l.calls.Add(1) }
+func (l *nopLogger) Trace(f string, v ...interface{}) { l.calls.Add(1) }
+
+// recordingLogger keeps the Debug and Warn messages containing match.
+type recordingLogger struct {
+	nopLogger
+	mu    sync.Mutex
+	match string
+	lines []string
+}
+
+func (l *recordingLogger) Debug(f string, v ...interface{}) { l.record(f, v...) }
+func (l *recordingLogger) Warn(f string, v ...interface{})  { l.record(f, v...) }
+
+func (l *recordingLogger) record(f string, v ...interface{}) {
+	line := fmt.Sprintf(f, v...)
+	if !strings.Contains(line, l.match) {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, line)
+}
+
+func TestTypedNilLogger(t *testing.T) {
+	var nilConsole *lumber.ConsoleLogger
+	var nilNop *nopLogger
+	for _, logger := range []Logger{nilConsole, nilNop} {
+		d := newTestDriver(t, &Options{Logger: logger, LogLevel: lumber.FATAL})
+		if isNil(d.log) {
+			t.Fatalf("%T: driver kept the nil logger", logger)
+		}
+		if err := d.Write("users", "john", map[string]int{"age": 1}); err != nil {
+			t.Fatal(err)
+		}
+		var v map[string]int
+		if err := d.Read("users", "john", &v); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestEmptyRecordFile(t *testing.T) {
+	for _, asNotFound := range []bool{false, true} {
+		d := newTestDriver(t, &Options{EmptyAsNotFound: asNotFound})
+		if err := d.Write("users", "john", map[string]int{"age": 1}); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(d.recordPath("users", "jane")+".json", nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		var v map[string]int
+		err := d.Read("users", "jane", &v)
+		if !errors.Is(err, ErrEmptyRecord) || errors.Is(err, ErrNotFound) != asNotFound {
+			t.Errorf("EmptyAsNotFound=%v: Read = %v", asNotFound, err)
+		}
+		var syntax *json.SyntaxError
+		if errors.As(err, &syntax) {
+			t.Errorf("EmptyAsNotFound=%v: Read returned the JSON error %v", asNotFound, err)
+		}
+
+		records, err := d.ReadAll("users")
+		if asNotFound && (err != nil || len(records) != 1) {
+			t.Errorf("ReadAll = %d records, %v, want john only", len(records), err)
+		}
+		if !asNotFound && !errors.Is(err, ErrEmptyRecord) {
+			t.Errorf("ReadAll = %v, want ErrEmptyRecord", err)
+		}
+	}
+}
+
+func TestReadInvalidTarget(t *testing.T) {
+	d := newTestDriver(t, nil)
+	if err := d.Write("users", "john", map[string]int{"age": 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	var nilMap *map[string]int
+	for _, target := range []interface{}{nil, map[string]int{}, nilMap, 42} {
+		// jane doesn't exist, so only an up-front check can report the
+		// target rather than ErrNotFound.
+		for _, name := range []string{"john", "jane"} {
+			if err := d.Read("users", name, target); !errors.Is(err, ErrInvalidTarget) {
+				t.Errorf("Read(%v, %T) = %v, want ErrInvalidTarget", name, target, err)
+			}
+			if _, err := d.ReadForUpdate("users", name, target); !errors.Is(err, ErrInvalidTarget) {
+				t.Errorf("ReadForUpdate(%v, %T) = %v, want ErrInvalidTarget", name, target, err)
+			}
+			if err := d.ReadVersion("users", name, 1, target); !errors.Is(err, ErrInvalidTarget) {
+				t.Errorf("ReadVersion(%v, %T) = %v, want ErrInvalidTarget", name, target, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"sort"
+	"sync"
+)
+
+// memoryStore is a Store kept in maps, for tests that should not touch the
+// disk. Records are stored as the same indented JSON a Driver writes.
+type memoryStore struct {
+	mutex       sync.Mutex
+	mutexes     map[string]*sync.RWMutex
+	collections map[string]map[string][]byte
+}
+
+// NewMemoryStore returns an empty in-memory Store with the semantics of a
+// Driver opened with default options: the same name checks and errors, and
+// a lock per collection.
+func NewMemoryStore() Store {
+	return &memoryStore{
+		mutexes:     make(map[string]*sync.RWMutex),
+		collections: make(map[string]map[string][]byte),
+	}
+}
+
+func (m *memoryStore) Write(collection, resource string, v interface{}) error {
+	if collection == "" {
+		return fmt.Errorf("%w - no place to save records", ErrMissingCollection)
+	}
+	if resource == "" {
+		return fmt.Errorf("%w - unable to save record (no name)!", ErrMissingResource)
+	}
+	if err := validNames(collection, resource); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, b, "", "\t"); err != nil {
+		return err
+	}
+	buf.WriteByte('\n')
+
+	mutex := m.getOrCreateMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	records, ok := m.collections[collection]
+	if !ok {
+		records = make(map[string][]byte)
+		m.collections[collection] = records
+	}
+	records[resource] = buf.Bytes()
+	return nil
+}
+
+func (m *memoryStore) Read(collection, resource string, v interface{}) error {
+	if collection == "" {
+		return fmt.Errorf("%w - unable to read", ErrMissingCollection)
+	}
+	if resource == "" {
+		return fmt.Errorf("%w - unable to read (no name)", ErrMissingResource)
+	}
+	if err := validNames(collection, resource); err != nil {
+		return err
+	}
+
+	mutex := m.getOrCreateMutex(collection)
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	b, ok := m.records(collection)[resource]
+	if !ok {
+		return fmt.Errorf("%w: %v/%v", ErrNotFound, collection, resource)
+	}
+	return json.Unmarshal(b, v)
+}
+
+func (m *memoryStore) ReadAll(collection string) ([]string, error) {
+	if collection == "" {
+		return nil, fmt.Errorf("%w - unable to read", ErrMissingCollection)
+	}
+	if err := validNames(collection); err != nil {
+		return nil, err
+	}
+
+	mutex := m.getOrCreateMutex(collection)
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	names, err := m.list(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	records := m.records(collection)
+	var all []string
+	for _, name := range names {
+		all = append(all, string(records[name]))
+	}
+	return all, nil
+}
+
+func (m *memoryStore) Delete(collection, resource string) error {
+	if collection == "" {
+		return fmt.Errorf("%w - unable to delete", ErrMissingCollection)
+	}
+	if err := validNames(collection, resource); err != nil {
+		return err
+	}
+
+	mutex := m.getOrCreateMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	records, ok := m.collections[collection]
+	if resource == "" && ok {
+		delete(m.collections, collection)
+		return nil
+	}
+	if _, found := records[resource]; !found {
+		return fmt.Errorf("%w: %v/%v", ErrNotFound, collection, resource)
+	}
+	delete(records, resource)
+	return nil
+}
+
+func (m *memoryStore) List(collection string) ([]string, error) {
+	if collection == "" {
+		return nil, fmt.Errorf("%w - unable to list", ErrMissingCollection)
+	}
+	if err := validNames(collection); err != nil {
+		return nil, err
+	}
+
+	mutex := m.getOrCreateMutex(collection)
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	return m.list(collection)
+}
+
+func (m *memoryStore) Count(collection string) (int, error) {
+	names, err := m.List(collection)
+	return len(names), err
+}
+
+func (m *memoryStore) Collections() ([]string, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var names []string
+	for name := range m.collections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// list fails like Driver.list, with an error matching fs.ErrNotExist, for
+// a collection that was never written. It must be called with the
+// collection lock held.
+func (m *memoryStore) list(collection string) ([]string, error) {
+	m.mutex.Lock()
+	records, ok := m.collections[collection]
+	var names []string
+	for name := range records {
+		names = append(names, name)
+	}
+	m.mutex.Unlock()
+
+	if !ok {
+		return nil, &fs.PathError{Op: "stat", Path: collection, Err: fs.ErrNotExist}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// records must be called with the collection lock held, which keeps the
+// returned map from changing.
+func (m *memoryStore) records(collection string) map[string][]byte {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.collections[collection]
+}
+
+func (m *memoryStore) getOrCreateMutex(collection string) *sync.RWMutex {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	mutex, ok := m.mutexes[collection]
+	if !ok {
+		mutex = &sync.RWMutex{}
+		m.mutexes[collection] = mutex
+	}
+	return mutex
+}
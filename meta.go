@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const metaFile = ".meta.json"
+
+// CollectionConfig holds the per-collection settings persisted in the
+// collection's hidden .meta.json file. They only control how records are
+// formatted: every collection is stored as plain, uncompressed JSON, since
+// Read, Scan, mmap reads, ReadField, the WAL and replicas all rely on that.
+// There is no per-collection codec, compression or schema setting; use
+// ValidateAgainst to check records against a type.
+type CollectionConfig struct {
+	// Compact writes records without indentation.
+	Compact bool `json:",omitempty"`
+	// OmitNewline drops the trailing newline Write normally appends to
+	// each record. Reads accept records with or without it.
+	OmitNewline bool `json:",omitempty"`
+}
+
+type collectionMeta struct {
+	Config   CollectionConfig
+	Sequence int64      `json:",omitempty"`
+	Indexes  []string   `json:",omitempty"`
+	Unique   [][]string `json:",omitempty"`
+	Sorted   []string   `json:",omitempty"`
+	Updated  time.Time
+}
+
+// ConfigureCollection stores cfg for collection. The settings are written to
+// disk and picked up again by New, so they survive restarts.
+func (d *Driver) ConfigureCollection(collection string, cfg CollectionConfig) error {
+	if collection == "" {
+		return fmt.Errorf("%w - unable to configure", ErrMissingCollection)
+	}
+	if err := validNames(collection); err != nil {
+		return err
+	}
+	if d.opts.ReadOnly {
+		return ErrReadOnly
+	}
+
+	if err := d.begin(); err != nil {
+		return err
+	}
+	defer d.end()
+
+	mutex := d.getOrCreateMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	meta := d.meta(collection)
+	meta.Config = cfg
+	return d.saveMeta(collection, meta)
+}
+
+// NextSequence increments and returns the collection's sequence counter.
+// The counter is persisted in the collection metadata, so it keeps counting
+// across restarts.
+func (d *Driver) NextSequence(collection string) (int64, error) {
+	if collection == "" {
+		return 0, fmt.Errorf("%w - unable to generate sequence", ErrMissingCollection)
+	}
+	if err := validNames(collection); err != nil {
+		return 0, err
+	}
+	if d.opts.ReadOnly {
+		return 0, ErrReadOnly
+	}
+
+	if err := d.begin(); err != nil {
+		return 0, err
+	}
+	defer d.end()
+
+	mutex := d.getOrCreateMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	meta := d.meta(collection)
+	meta.Sequence++
+	if err := d.saveMeta(collection, meta); err != nil {
+		return 0, err
+	}
+	return meta.Sequence, nil
+}
+
+func (d *Driver) config(collection string) CollectionConfig {
+	return d.meta(collection).Config
+}
+
+func (d *Driver) meta(collection string) collectionMeta {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if m, ok := d.metas[d.fold(collection)]; ok {
+		return m
+	}
+	return collectionMeta{}
+}
+
+// saveMeta must be called with the collection lock held.
+func (d *Driver) saveMeta(collection string, meta collectionMeta) error {
+	if d.opts.ReadOnly {
+		return ErrReadOnly
+	}
+	dir := d.collectionDir(collection)
+	if err := os.MkdirAll(dir, d.dirMode); err != nil {
+		d.log.Error("Failed to create directory: %v", err)
+		return err
+	}
+
+	meta.Updated = d.now()
+	b, err := json.MarshalIndent(meta, "", "\t")
+	if err != nil {
+		return err
+	}
+	b = append(b, byte('\n'))
+
+	if err := d.writeFile(filepath.Join(dir, metaFile), b); err != nil {
+		return err
+	}
+
+	d.mutex.Lock()
+	d.metas[d.fold(collection)] = meta
+	d.mutex.Unlock()
+	return nil
+}
+
+func (d *Driver) loadMetas() error {
+	entries, err := os.ReadDir(d.dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if !isDir(d.dir, entry) || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		b, err := os.ReadFile(filepath.Join(d.dir, entry.Name(), metaFile))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		var meta collectionMeta
+		if err := json.Unmarshal(b, &meta); err != nil {
+			return fmt.Errorf("invalid metadata for collection %v: %v", entry.Name(), err)
+		}
+
+		d.log.Debug("Loaded metadata for collection '%s'", entry.Name())
+		d.metas[entry.Name()] = meta
+	}
+	return nil
+}
+
+// isRecord reports whether a directory entry name is a stored record, as
+// opposed to a temp file or hidden metadata.
+func isRecord(name string) bool {
+	return strings.HasSuffix(name, ".json") && !strings.HasPrefix(name, ".")
+}
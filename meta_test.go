@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestOmitNewlineRoundTrip(t *testing.T) {
+	for _, omit := range []bool{false, true} {
+		d := newTestDriver(t, nil)
+		if err := d.ConfigureCollection("users", CollectionConfig{OmitNewline: omit}); err != nil {
+			t.Fatal(err)
+		}
+		want := map[string]string{"bio": "line one\nline two\n"}
+		if err := d.Write("users", "john", want); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, step := range []string{"Write", "CompactRecord"} {
+			if step == "CompactRecord" {
+				if err := d.CompactRecord("users", "john"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			b, err := os.ReadFile(d.recordPath("users", "john") + ".json")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bytes.HasSuffix(b, []byte("\n")) == omit {
+				t.Errorf("OmitNewline=%v: %v left %q", omit, step, b)
+			}
+
+			var got map[string]string
+			if err := d.Read("users", "john", &got); err != nil || got["bio"] != want["bio"] {
+				t.Errorf("OmitNewline=%v: read %q, %v after %v", omit, got, err, step)
+			}
+		}
+
+		// Records written under the other setting still read back.
+		if err := d.ConfigureCollection("users", CollectionConfig{OmitNewline: !omit}); err != nil {
+			t.Fatal(err)
+		}
+		var got map[string]string
+		if err := d.Read("users", "john", &got); err != nil || got["bio"] != want["bio"] {
+			t.Errorf("OmitNewline=%v: read %q, %v after reconfiguring", omit, got, err)
+		}
+	}
+}
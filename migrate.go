@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// Migrate passes the raw bytes of every record in collection to fn and
+// writes back what it returns, e.g. to rename a field or change its type
+// after the record struct evolved. Each record is replaced atomically; the
+// returned count is the number of records that changed. When fn fails for a
+// record, Migrate stops with that error unless Options.SkipMigrationErrors
+// is set, in which case the record is logged and left as it was.
+func (d *Driver) Migrate(collection string, fn func(raw []byte) ([]byte, error)) (int, error) {
+	if collection == "" {
+		return 0, fmt.Errorf("%w - unable to migrate", ErrMissingCollection)
+	}
+	if err := validNames(collection); err != nil {
+		return 0, err
+	}
+
+	mutex := d.getOrCreateMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	names, err := d.list(collection)
+	if err != nil {
+		return 0, err
+	}
+
+	migrated := 0
+	for _, name := range names {
+		b, err := d.read(collection, name)
+		if err != nil {
+			return migrated, err
+		}
+
+		out, err := migrateRecord(b, fn)
+		if err != nil {
+			if d.opts.SkipMigrationErrors {
+				d.log.Warn("Skipping migration of %v/%v: %v", collection, name, err)
+				continue
+			}
+			return migrated, fmt.Errorf("unable to migrate %v/%v: %w", collection, name, err)
+		}
+		if bytes.Equal(out, b) {
+			continue
+		}
+
+		if err := d.write(collection, name, out); err != nil {
+			return migrated, err
+		}
+		migrated++
+	}
+	return migrated, nil
+}
+
+func migrateRecord(b []byte, fn func(raw []byte) ([]byte, error)) ([]byte, error) {
+	out, err := fn(bytes.Clone(b))
+	if err != nil {
+		return nil, err
+	}
+	if !json.Valid(out) {
+		return nil, fmt.Errorf("invalid JSON returned by migration")
+	}
+	return out, nil
+}
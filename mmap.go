@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// readMapped is Read for Options.UseMmap, decoding the record into v while
+// its file is mapped. It does not consult the fallback. Like Read it takes
+// no collection lock.
+func (d *Driver) readMapped(collection, resource string, v interface{}) error {
+	if d.opts.StaleTmp != IgnoreStaleTmp {
+		d.checkStaleTmp(collection, resource)
+	}
+
+	path := d.recordPath(collection, resource) + ".json"
+	decode := func(b []byte) error {
+		if err := d.checkEmpty(collection, resource, b); err != nil {
+			return err
+		}
+		if d.opts.Checksum {
+			if err := d.verifyChecksum(collection, resource, b); err != nil {
+				return err
+			}
+		}
+		return d.decode(collection, resource, b, v)
+	}
+
+	err := mapFile(path, decode)
+	if errors.Is(err, ErrChecksumMismatch) {
+		// See read: a concurrent write may have replaced the record
+		// between mapping it and reading its checksum.
+		err = mapFile(path, decode)
+	}
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%w: %w", ErrNotFound, err)
+	}
+	return err
+}
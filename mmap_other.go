@@ -0,0 +1,15 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// mapFile calls fn with the contents of the file at path. Without mmap
+// support it is read normally.
+func mapFile(path string, fn func(b []byte) error) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return fn(b)
+}
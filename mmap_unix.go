@@ -0,0 +1,37 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// mapFile calls fn with the contents of the file at path mapped read-only
+// into memory. The file is closed as soon as it is mapped and the mapping
+// is released when fn returns, so fn must not retain b. Records are only
+// ever replaced by rename, never truncated in place, so the mapping stays
+// valid for that long.
+func mapFile(path string, fn func(b []byte) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if fi.Size() == 0 {
+		f.Close()
+		return fn(nil)
+	}
+
+	b, err := syscall.Mmap(int(f.Fd()), 0, int(fi.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	defer syscall.Munmap(b)
+	return fn(b)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// collectionLock is a collection's RWMutex, counting the callers that have
+// looked it up with getOrCreateMutex and not yet released it, so that
+// PruneMutexes only drops locks nobody is about to take. Every lookup must
+// be followed by exactly one Lock or RLock and its unlock.
+type collectionLock struct {
+	sync.RWMutex
+	users atomic.Int64
+}
+
+func (l *collectionLock) Unlock() {
+	l.RWMutex.Unlock()
+	l.users.Add(-1)
+}
+
+func (l *collectionLock) RUnlock() {
+	l.RWMutex.RUnlock()
+	l.users.Add(-1)
+}
+
+// MutexCount returns the number of collection locks the driver holds in
+// memory, one per collection ever accessed until PruneMutexes drops them.
+func (d *Driver) MutexCount() int {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return len(d.mutexes)
+}
+
+// PruneMutexes drops the locks and rate limiters of collections that no
+// longer exist on disk, so that applications creating many short-lived
+// collections don't grow the driver's maps forever. Locks held, or looked
+// up by an operation about to take them, are kept. It returns the number of
+// locks dropped.
+func (d *Driver) PruneMutexes() int {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	// Lookups count themselves under d.mutex, so a lock with no users
+	// can't gain one before it is dropped.
+	n := 0
+	for name, m := range d.mutexes {
+		if m.users.Load() == 0 && !d.collectionExists(name) {
+			delete(d.mutexes, name)
+			delete(d.buckets, name)
+			n++
+		}
+	}
+	return n
+}
+
+func (d *Driver) collectionExists(collection string) bool {
+	path := d.collectionDir(collection)
+	if d.opts.SingleFilePerCollection {
+		path = d.singlePath(collection)
+	}
+	_, err := os.Stat(path)
+	return err == nil
+}
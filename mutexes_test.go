@@ -0,0 +1,61 @@
+package main
+
+import "testing"
+
+func TestPruneMutexes(t *testing.T) {
+	d := newTestDriver(t, nil)
+	if err := d.Write("users", "john", map[string]int{"age": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Write("tmp", "x", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Delete("tmp", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// A lock looked up but not yet taken survives pruning.
+	m := d.getOrCreateMutex("gone")
+	if n := d.PruneMutexes(); n != 1 {
+		t.Fatalf("PruneMutexes = %d, want 1 (tmp)", n)
+	}
+	m.Lock()
+	m.Unlock()
+	again := d.getOrCreateMutex("gone")
+	again.RLock()
+	again.RUnlock()
+	if again != m {
+		t.Fatal("PruneMutexes dropped a lock that was about to be taken")
+	}
+
+	// Both lookups of gone are now released.
+	if n := d.PruneMutexes(); n != 1 {
+		t.Fatalf("PruneMutexes = %d, want 1 (gone)", n)
+	}
+	if n := d.MutexCount(); n != 1 {
+		t.Fatalf("MutexCount = %d, want 1 (users)", n)
+	}
+}
+
+// TestRenameKeepsLock checks that a writer that looked up a renamed
+// collection's lock before the rename shares it with one arriving after.
+func TestRenameKeepsLock(t *testing.T) {
+	d := newTestDriver(t, nil)
+	if err := d.Write("old", "r", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	waiting := d.getOrCreateMutex("old")
+	if err := d.RenameCollection("old", "new"); err != nil {
+		t.Fatal(err)
+	}
+	waiting.Lock()
+	waiting.Unlock()
+
+	arriving := d.getOrCreateMutex("old")
+	arriving.Lock()
+	arriving.Unlock()
+	if waiting != arriving {
+		t.Fatal("RenameCollection replaced the lock of the old name")
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxNameLength leaves room for the longest extension and temp suffix
+// within the 255 byte file name limit of common filesystems.
+const maxNameLength = 240
+
+// ValidName reports whether name can be used as a collection or resource
+// name, returning an error wrapping ErrInvalidName if not. It rejects empty
+// names and ones that would escape the database directory, collide with
+// hidden metadata files, or exceed the filesystem's name limit. Methods
+// such as Write apply the same rules, but report empty names as
+// ErrMissingCollection or ErrMissingResource instead.
+func ValidName(name string) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("%w: empty", ErrInvalidName)
+	case strings.ContainsAny(name, `/\`):
+		return fmt.Errorf("%w %q: contains a path separator", ErrInvalidName, name)
+	case strings.Contains(name, ".."):
+		return fmt.Errorf("%w %q: contains \"..\"", ErrInvalidName, name)
+	case strings.HasPrefix(name, "."):
+		return fmt.Errorf("%w %q: starts with \".\"", ErrInvalidName, name)
+	case strings.ContainsRune(name, 0):
+		return fmt.Errorf("%w %q: contains a NUL byte", ErrInvalidName, name)
+	case len(name) > maxNameLength:
+		return fmt.Errorf("%w %q: longer than %d bytes", ErrInvalidName, name, maxNameLength)
+	}
+	return nil
+}
+
+// validNames applies ValidName to each non-empty name.
+func validNames(names ...string) error {
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		if err := ValidName(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidName(t *testing.T) {
+	for _, name := range []string{"users", "a.b", "John Smith", "x-1_2"} {
+		if err := ValidName(name); err != nil {
+			t.Errorf("ValidName(%q) = %v, want nil", name, err)
+		}
+	}
+	for _, name := range []string{"", "..", "a/b", `a\b`, "a..b", ".hidden", "a\x00b", strings.Repeat("x", maxNameLength+1)} {
+		if err := ValidName(name); !errors.Is(err, ErrInvalidName) {
+			t.Errorf("ValidName(%q) = %v, want ErrInvalidName", name, err)
+		}
+	}
+}
+
+// TestInvalidNamesRejected checks that no entry point lets a name escape
+// the database directory, by planting a record next to it.
+func TestInvalidNamesRejected(t *testing.T) {
+	d := newTestDriver(t, nil)
+	secret := filepath.Join(filepath.Dir(d.dir), "secret.json")
+	if err := os.WriteFile(secret, []byte(`{"pw":"hunter2"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Write("users", "a", map[string]string{"pw": "x"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var v map[string]string
+	noop := func(current json.RawMessage) (json.RawMessage, error) { return json.RawMessage(`"owned"`), nil }
+	checks := map[string]func() error{
+		"Write":               func() error { return d.Write("..", "secret", v) },
+		"Read":                func() error { return d.Read("..", "secret", &v) },
+		"ReadRaw":             func() error { _, err := d.ReadRaw("..", "secret"); return err },
+		"WriteRaw":            func() error { return d.WriteRaw("..", "secret", []byte("{}")) },
+		"Delete":              func() error { return d.Delete("..", "secret") },
+		"List":                func() error { _, err := d.List(".."); return err },
+		"Glob":                func() error { _, err := d.Glob("..", "*"); return err },
+		"Count":               func() error { _, err := d.Count(".."); return err },
+		"ApproxCount":         func() error { _, err := d.ApproxCount(".."); return err },
+		"ReadAll":             func() error { _, err := d.ReadAll(".."); return err },
+		"ReadAllPartial":      func() error { _, failed := d.ReadAllPartial(".."); return failed[""] },
+		"Scan":                func() error { return d.Scan("..", func(string, []byte) (bool, error) { return false, nil }) },
+		"ReadField":           func() error { _, err := d.ReadField("..", "secret", "pw"); return err },
+		"UpdateField":         func() error { return d.UpdateField("..", "secret", "pw", noop) },
+		"UpdateField/res":     func() error { return d.UpdateField("users", "../../secret", "pw", noop) },
+		"ConfigureCollection": func() error { return d.ConfigureCollection("..", CollectionConfig{}) },
+		"NextSequence":        func() error { _, err := d.NextSequence(".."); return err },
+		"CompactRecord":       func() error { return d.CompactRecord("..", "secret") },
+		"CompactCollection":   func() error { return d.CompactCollection("..") },
+		"Migrate": func() error {
+			_, err := d.Migrate("..", func(b []byte) ([]byte, error) { return b, nil })
+			return err
+		},
+		"Swap": func() error { return d.Swap("users", "a", "../../secret") },
+		"DeleteWhere": func() error {
+			_, err := DeleteWhere(d, "..", func(map[string]string) bool { return true })
+			return err
+		},
+		"Deduplicate": func() error {
+			_, err := d.Deduplicate("..", func([]byte) (string, error) { return "", nil })
+			return err
+		},
+		"CreateIndex":         func() error { return d.CreateIndex("..", "pw") },
+		"CreateIndex/field":   func() error { return d.CreateIndex("users", "../../x") },
+		"FindByIndex":         func() error { _, err := d.FindByIndex("..", "pw", "x"); return err },
+		"CountByIndex":        func() error { _, err := d.CountByIndex("..", "pw", "x"); return err },
+		"Reindex":             func() error { return d.Reindex("..") },
+		"CreateSortedIndex":   func() error { return d.CreateSortedIndex("users", "../../x") },
+		"RangeQuery":          func() error { _, err := d.RangeQuery("..", "pw", "", ""); return err },
+		"SetUniqueConstraint": func() error { return d.SetUniqueConstraint("users", []string{"../x"}) },
+		"History":             func() error { _, err := d.History("..", "secret"); return err },
+		"ReadVersion":         func() error { return d.ReadVersion("..", "secret", 1, &v) },
+		"Revert":              func() error { return d.Revert("..", "secret", 1) },
+		"Equal":               func() error { _, err := d.Equal("..", "secret", "secret"); return err },
+		"WriteBlob":           func() error { return d.WriteBlob("..", "secret", strings.NewReader("x")) },
+		"ReadBlob":            func() error { _, err := d.ReadBlob("..", "secret"); return err },
+		"ListBlobs":           func() error { _, err := d.ListBlobs(".."); return err },
+		"CollectionHash":      func() error { _, err := d.CollectionHash(".."); return err },
+		"Manifest":            func() error { _, err := d.Manifest(".."); return err },
+		"ArchiveCollection":   func() error { return d.ArchiveCollection("..", &bytes.Buffer{}) },
+		"LinkCollection":      func() error { return d.LinkCollection("..", t.TempDir()) },
+		"ExportCSV":           func() error { return d.ExportCSV("..", []string{"pw"}, &bytes.Buffer{}) },
+		"FieldStats":          func() error { _, _, _, _, err := d.FieldStats("..", "pw"); return err },
+		"Where":               func() error { _, err := d.Where("..", "pw", "eq", "x"); return err },
+		"Sample":              func() error { _, err := d.Sample("..", 1); return err },
+		"ReadModifiedBetween": func() error {
+			_, err := d.ReadModifiedBetween("..", time.Time{}, time.Now())
+			return err
+		},
+		"FindN": func() error {
+			_, err := FindN(d, "..", 1, func(map[string]string) bool { return true })
+			return err
+		},
+		"ValidateAgainst": func() error { _, err := ValidateAgainst[map[string]string](d, ".."); return err },
+		"RecordsWithError": func() error {
+			seq, errf := RecordsWithError[map[string]string](d, "..")
+			for range seq {
+			}
+			return errf()
+		},
+		"Stream": func() error {
+			records, errs := d.Stream("..")
+			for range records {
+			}
+			return <-errs
+		},
+		"ReadAllTyped": func() error {
+			d.RegisterType("..", map[string]string{})
+			_, err := d.ReadAllTyped("..")
+			return err
+		},
+		"Insert":           func() error { _, err := d.Insert("..", v); return err },
+		"RenameCollection": func() error { return d.RenameCollection("users", "../x") },
+		"CreateCollection": func() error { return d.CreateCollection("..") },
+		"AppendToArray":    func() error { return d.AppendToArray("..", "secret", 1) },
+	}
+
+	for name, check := range checks {
+		if err := check(); !errors.Is(err, ErrInvalidName) {
+			t.Errorf("%s: got %v, want ErrInvalidName", name, err)
+		}
+	}
+
+	b, err := os.ReadFile(secret)
+	if err != nil || string(b) != `{"pw":"hunter2"}` {
+		t.Errorf("file outside the database changed: %q, %v", b, err)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(d.dir), metaFile)); !os.IsNotExist(err) {
+		t.Errorf("metadata written outside the database: %v", err)
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// DeleteWhere removes every record in collection for which pred returns
+// true and reports how many were deleted. The whole pass runs under the
+// collection lock.
+func DeleteWhere[T any](d *Driver, collection string, pred func(T) bool) (int, error) {
+	if collection == "" {
+		return 0, fmt.Errorf("%w - unable to delete", ErrMissingCollection)
+	}
+	if err := validNames(collection); err != nil {
+		return 0, err
+	}
+
+	mutex := d.getOrCreateMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	names, err := d.list(collection)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, name := range names {
+		b, err := d.read(collection, name)
+		if err != nil {
+			return deleted, err
+		}
+
+		var v T
+		if err := d.decode(collection, name, b, &v); err != nil {
+			return deleted, fmt.Errorf("unable to decode %v: %w", name, err)
+		}
+		if !pred(v) {
+			continue
+		}
+
+		d.log.Debug("Deleting record: %s/%s", collection, name)
+		if err := d.remove(collection, name); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// Scan calls fn with the name and stored bytes of each record in
+// collection, reading one file at a time in name order. Iteration ends when
+// fn returns stop=true, or with fn's error if it returns one. Scan holds the
+// collection read lock for its whole duration.
+func (d *Driver) Scan(collection string, fn func(resource string, raw []byte) (stop bool, err error)) error {
+	if collection == "" {
+		return fmt.Errorf("%w - unable to read", ErrMissingCollection)
+	}
+	if err := validNames(collection); err != nil {
+		return err
+	}
+
+	mutex := d.getOrCreateMutex(collection)
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	return d.scan(collection, fn)
+}
+
+// scan must be called with the collection lock held.
+func (d *Driver) scan(collection string, fn func(resource string, raw []byte) (bool, error)) error {
+	names, err := d.list(collection)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		b, err := d.read(collection, name)
+		if err != nil {
+			return err
+		}
+
+		stop, err := fn(name, b)
+		if err != nil || stop {
+			return err
+		}
+	}
+	return nil
+}
+
+// FilterToNDJSON writes every record in collection for which pred returns
+// true to w as newline-delimited JSON. Records are read, tested and written
+// one at a time, so memory use doesn't grow with the collection.
+func FilterToNDJSON[T any](d *Driver, collection string, pred func(T) bool, w io.Writer) error {
+	bw := bufio.NewWriterSize(w, d.opts.WriteBufferSize)
+	var buf bytes.Buffer
+	err := d.Scan(collection, func(name string, b []byte) (bool, error) {
+		var v T
+		if err := json.Unmarshal(b, &v); err != nil {
+			return false, fmt.Errorf("unable to decode %v: %v", name, err)
+		}
+		if !pred(v) {
+			return false, nil
+		}
+
+		buf.Reset()
+		if err := json.Compact(&buf, b); err != nil {
+			return false, err
+		}
+		buf.WriteByte('\n')
+		_, err := bw.Write(buf.Bytes())
+		return false, err
+	})
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// FindN returns up to n records of collection for which pred returns true,
+// testing records in name order and stopping as soon as n have matched, so
+// the result is the same for the same data. It returns fewer than n when the
+// collection runs out.
+func FindN[T any](d *Driver, collection string, n int, pred func(T) bool) ([]T, error) {
+	var found []T
+	if n <= 0 {
+		return found, nil
+	}
+
+	err := d.Scan(collection, func(name string, b []byte) (bool, error) {
+		var v T
+		if err := d.decode(collection, name, b, &v); err != nil {
+			return false, fmt.Errorf("unable to decode %v: %v", name, err)
+		}
+		if pred(v) {
+			found = append(found, v)
+		}
+		return len(found) >= n, nil
+	})
+	return found, err
+}
+
+// ValidateAgainst decodes every record of collection into a T, the way Read
+// would, and returns the errors of the records that fail, keyed by name. It
+// is meant for checking stored data against a changed type before
+// migrating. err is only set when the collection can't be read.
+func ValidateAgainst[T any](d *Driver, collection string) (bad map[string]error, err error) {
+	bad = make(map[string]error)
+	err = d.Scan(collection, func(name string, b []byte) (bool, error) {
+		var v T
+		if err := d.decode(collection, name, b, &v); err != nil {
+			bad[name] = err
+		}
+		return false, nil
+	})
+	return bad, err
+}
+
+// ReadModifiedBetween returns the records of collection whose files were
+// last modified at or after start and before end, keyed by name.
+//
+// Modification times are the filesystem's: they are only as precise as it
+// is, and copying or restoring a database without preserving them (e.g.
+// LoadJSON, or a plain cp) resets them to the time of the copy. Store a
+// timestamp in the records themselves when that matters. It isn't supported
+// with SingleFilePerCollection, which has one file for all the records.
+func (d *Driver) ReadModifiedBetween(collection string, start, end time.Time) (map[string][]byte, error) {
+	if collection == "" {
+		return nil, fmt.Errorf("%w - unable to read", ErrMissingCollection)
+	}
+	if err := validNames(collection); err != nil {
+		return nil, err
+	}
+	if d.opts.SingleFilePerCollection {
+		return nil, fmt.Errorf("ReadModifiedBetween is not supported with SingleFilePerCollection")
+	}
+
+	mutex := d.getOrCreateMutex(collection)
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	names, err := d.list(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make(map[string][]byte)
+	for _, name := range names {
+		fi, err := os.Stat(d.recordPath(collection, name) + ".json")
+		if err != nil {
+			return nil, err
+		}
+		if mtime := fi.ModTime(); mtime.Before(start) || !mtime.Before(end) {
+			continue
+		}
+
+		b, err := d.read(collection, name)
+		if err != nil {
+			return nil, err
+		}
+		records[name] = b
+	}
+	return records, nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestDeleteWhereDecodesLikeRead(t *testing.T) {
+	repaired := 0
+	d := newTestDriver(t, &Options{
+		KeyField: "id",
+		OnCorrupt: func(collection, resource string, raw []byte, err error) ([]byte, error) {
+			repaired++
+			return []byte(`{"age":40}`), nil
+		},
+	})
+	for name, age := range map[string]int{"john": 30, "jane": 20} {
+		if err := d.Write("users", name, map[string]int{"age": age}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(d.recordPath("users", "joe")+".json", []byte(`{"age":`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var seen []map[string]interface{}
+	deleted, err := DeleteWhere(d, "users", func(v map[string]interface{}) bool {
+		seen = append(seen, v)
+		return v["age"].(float64) > 25
+	})
+	if err != nil || deleted != 2 {
+		t.Fatalf("DeleteWhere = %v, %v, want 2 (john and the repaired joe)", deleted, err)
+	}
+	if repaired != 1 {
+		t.Errorf("OnCorrupt called %d times, want 1", repaired)
+	}
+
+	var jane map[string]interface{}
+	if err := d.Read("users", "jane", &jane); err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range seen {
+		if v["age"] == jane["age"] && !reflect.DeepEqual(v, jane) {
+			t.Errorf("predicate saw %v, Read returns %v", v, jane)
+		}
+	}
+}
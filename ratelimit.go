@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimitMode decides what Write, Read and Delete do when a collection is
+// over Options.RateLimit.
+type RateLimitMode int
+
+const (
+	// RateLimitWait blocks until the operation is allowed, or until
+	// OperationTimeout expires.
+	RateLimitWait RateLimitMode = iota
+	// RateLimitReject fails the operation with ErrRateLimited.
+	RateLimitReject
+)
+
+// bucket is the token bucket limiting one collection.
+type bucket struct {
+	mutex  sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// limit takes a token from the collection's bucket, waiting for one or
+// failing according to RateLimitMode. It must be called without the
+// collection lock, so that waiting doesn't hold up other operations.
+func (d *Driver) limit(ctx context.Context, collection string) error {
+	rate := d.opts.RateLimit
+	if rate <= 0 {
+		return nil
+	}
+	burst := float64(d.opts.RateLimitBurst)
+	if burst <= 0 {
+		burst = math.Max(1, math.Floor(rate))
+	}
+
+	d.mutex.Lock()
+	if d.buckets == nil {
+		d.buckets = make(map[string]*bucket)
+	}
+	b, ok := d.buckets[d.fold(collection)]
+	if !ok {
+		b = &bucket{tokens: burst, last: d.now()}
+		d.buckets[d.fold(collection)] = b
+	}
+	d.mutex.Unlock()
+
+	b.mutex.Lock()
+	now := d.now()
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		b.mutex.Unlock()
+		return nil
+	}
+	if d.opts.RateLimitMode == RateLimitReject {
+		b.mutex.Unlock()
+		return ErrRateLimited
+	}
+
+	// Reserve the next token and sleep until it is due.
+	b.tokens--
+	wait := time.Duration(-b.tokens / rate * float64(time.Second))
+	b.mutex.Unlock()
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
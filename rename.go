@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// RenameCollection renames the collection oldName to newName with a single
+// os.Rename, under the locks of both. It fails if newName already exists.
+// The lock of oldName is kept, so operations already waiting on it see the
+// collection gone once it is released; PruneMutexes can drop it later.
+func (d *Driver) RenameCollection(oldName, newName string) error {
+	if oldName == "" || newName == "" {
+		return fmt.Errorf("%w - unable to rename", ErrMissingCollection)
+	}
+	if err := validNames(oldName, newName); err != nil {
+		return err
+	}
+	if d.opts.ReadOnly {
+		return ErrReadOnly
+	}
+	if d.fold(oldName) == d.fold(newName) {
+		return fmt.Errorf("unable to rename collection %v to itself", oldName)
+	}
+
+	if err := d.begin(); err != nil {
+		return err
+	}
+	defer d.end()
+
+	// Lock in name order so that concurrent renames can't deadlock.
+	first, second := oldName, newName
+	if d.fold(second) < d.fold(first) {
+		first, second = second, first
+	}
+	for _, name := range []string{first, second} {
+		mutex := d.getOrCreateMutex(name)
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		unlock, err := d.lockFile(name)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
+	oldPath, newPath := d.collectionDir(oldName), d.collectionDir(newName)
+	if d.opts.SingleFilePerCollection {
+		oldPath, newPath = d.singlePath(oldName), d.singlePath(newName)
+	}
+	if _, err := os.Lstat(newPath); err == nil {
+		return fmt.Errorf("collection %v already exists - unable to rename %v", newName, oldName)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	d.log.Debug("Renaming collection '%s' to '%s'", oldName, newName)
+	if err := os.Rename(oldPath, newPath); err != nil {
+		return err
+	}
+	d.renameMirror(oldPath, newPath)
+
+	d.mutex.Lock()
+	if meta, ok := d.metas[d.fold(oldName)]; ok {
+		d.metas[d.fold(newName)] = meta
+		delete(d.metas, d.fold(oldName))
+	}
+	d.mutex.Unlock()
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Reopen points the driver at newDir, e.g. after the database directory was
+// moved or restored from a backup, without building a new Driver. newDir
+// must be an existing directory. Reopen waits for the collection locks held
+// by operations already running, then reloads the collection metadata from
+// newDir and, with the WAL enabled, switches to and replays the log found
+// there. Operations that take no lock, like Read, are not waited for, so
+// callers must let in-flight operations complete and start no new ones
+// until Reopen returns.
+func (d *Driver) Reopen(newDir string) error {
+	newDir = filepath.Clean(newDir)
+	fi, err := os.Stat(newDir)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("unable to reopen: %v is not a directory", newDir)
+	}
+
+	if err := d.begin(); err != nil {
+		return err
+	}
+	defer d.end()
+
+	if err := d.relocate(newDir); err != nil {
+		return err
+	}
+	if !d.useWAL || d.opts.ReadOnly {
+		return nil
+	}
+
+	// Replay takes the collection locks itself, so this happens after
+	// relocate has released them.
+	if err := d.closeWAL(); err != nil {
+		return err
+	}
+	return d.openWAL()
+}
+
+// relocate swaps d.dir for dir while holding every collection lock.
+func (d *Driver) relocate(dir string) error {
+	d.mutex.Lock()
+	names := make([]string, 0, len(d.mutexes))
+	for name := range d.mutexes {
+		names = append(names, name)
+	}
+	d.mutex.Unlock()
+
+	sort.Strings(names)
+	for _, name := range names {
+		mutex := d.getOrCreateMutex(name)
+		mutex.Lock()
+		defer mutex.Unlock()
+	}
+
+	d.mutex.Lock()
+	old := d.dir
+	d.dir = dir
+	d.metas = make(map[string]collectionMeta)
+	d.mutex.Unlock()
+
+	if err := d.loadMetas(); err != nil {
+		return err
+	}
+	d.log.Info("Reopened database '%s' at '%s'", old, dir)
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Read replicas
+//
+// With Options.ReplicaDirs every record written is copied to the same
+// relative path in each replica directory right after the rename that
+// commits it in the primary, and every delete is repeated there. Mirroring
+// is best effort: a failure is logged and doesn't fail the write, so a
+// replica can miss updates until the record is written again.
+//
+// A follower is a Driver opened on a replica directory with
+// Options.ReadOnly. It serves reads lock-free like the primary, but is only
+// eventually consistent with it: a record written to the primary becomes
+// visible on the follower shortly after, and records are mirrored one at a
+// time, so a follower can see some of a batch of writes and not the rest.
+// Collection renames are repeated in the replicas too.
+// Only records are mirrored; indexes, metadata, blobs, versions and
+// checksums stay with the primary.
+
+// mirror copies the record just written at path to the replicas.
+func (d *Driver) mirror(path string, b []byte) {
+	for _, replica := range d.opts.ReplicaDirs {
+		target, err := d.replicaPath(replica, path)
+		if err == nil {
+			err = os.MkdirAll(filepath.Dir(target), d.dirMode)
+		}
+		if err == nil {
+			err = d.writeFile(target, b)
+		}
+		if err != nil {
+			d.log.Warn("Failed to mirror %s to replica %s: %v", path, replica, err)
+		}
+	}
+}
+
+// unmirror removes path, a record or a whole collection, from the replicas.
+func (d *Driver) unmirror(path string) {
+	for _, replica := range d.opts.ReplicaDirs {
+		target, err := d.replicaPath(replica, path)
+		if err == nil {
+			err = os.RemoveAll(target)
+		}
+		if err != nil {
+			d.log.Warn("Failed to remove %s from replica %s: %v", path, replica, err)
+		}
+	}
+}
+
+func (d *Driver) replicaPath(replica, path string) (string, error) {
+	rel, err := filepath.Rel(d.dir, path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(replica, rel), nil
+}
+
+// renameMirror repeats the rename of a collection in the replicas.
+func (d *Driver) renameMirror(oldPath, newPath string) {
+	for _, replica := range d.opts.ReplicaDirs {
+		from, err := d.replicaPath(replica, oldPath)
+		if err != nil {
+			d.log.Warn("Failed to rename %s in replica %s: %v", oldPath, replica, err)
+			continue
+		}
+		to, err := d.replicaPath(replica, newPath)
+		if err == nil {
+			err = os.Rename(from, to)
+		}
+		if err != nil && !os.IsNotExist(err) {
+			d.log.Warn("Failed to rename %s in replica %s: %v", oldPath, replica, err)
+		}
+	}
+}
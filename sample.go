@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Sample returns up to n records of collection picked at random, in name
+// order. Only the names are listed in full; a reservoir sample is drawn from
+// them and just the sampled records are read. Set Options.SampleSeed for a
+// reproducible sequence of samples.
+func (d *Driver) Sample(collection string, n int) ([]string, error) {
+	if collection == "" {
+		return nil, fmt.Errorf("%w - unable to read", ErrMissingCollection)
+	}
+	if err := validNames(collection); err != nil {
+		return nil, err
+	}
+	if n <= 0 {
+		return []string{}, nil
+	}
+
+	mutex := d.getOrCreateMutex(collection)
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	names, err := d.list(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	d.rngMutex.Lock()
+	picked := make([]int, 0, n)
+	for i := range names {
+		if len(picked) < n {
+			picked = append(picked, i)
+		} else if j := d.rng.Intn(i + 1); j < n {
+			picked[j] = i
+		}
+	}
+	d.rngMutex.Unlock()
+	sort.Ints(picked)
+
+	records := make([]string, 0, len(picked))
+	for _, i := range picked {
+		b, err := d.read(collection, names[i])
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, string(b))
+	}
+	return records, nil
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSampleSeededFromClock(t *testing.T) {
+	clock := func() time.Time { return time.Unix(1700000000, 0) }
+	var samples [][]string
+	for i := 0; i < 2; i++ {
+		d := newTestDriver(t, &Options{Clock: clock})
+		for j := 0; j < 50; j++ {
+			if err := d.Write("users", "r"+strconv.Itoa(j), j); err != nil {
+				t.Fatal(err)
+			}
+		}
+		sample, err := d.Sample("users", 5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		samples = append(samples, sample)
+	}
+	if !reflect.DeepEqual(samples[0], samples[1]) {
+		t.Errorf("samples with the same Clock differ: %v and %v", samples[0], samples[1])
+	}
+}
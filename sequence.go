@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// sequencesFile holds the GlobalSequence counters at the database root.
+const sequencesFile = ".sequences.json"
+
+// GlobalSequence increments and returns the named counter, which unlike
+// NextSequence belongs to the database rather than to a collection, for ID
+// spaces shared by several collections. Counters are persisted together at
+// the database root and updated under a mutex of their own.
+func (d *Driver) GlobalSequence(name string) (int64, error) {
+	if name == "" {
+		return 0, fmt.Errorf("missing name - unable to generate sequence")
+	}
+	if d.opts.ReadOnly {
+		return 0, ErrReadOnly
+	}
+
+	if err := d.begin(); err != nil {
+		return 0, err
+	}
+	defer d.end()
+
+	d.seqMutex.Lock()
+	defer d.seqMutex.Unlock()
+
+	path := filepath.Join(d.dir, sequencesFile)
+	sequences := map[string]int64{}
+	b, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(b, &sequences); err != nil {
+			return 0, fmt.Errorf("invalid sequences file: %v", err)
+		}
+	}
+
+	sequences[name]++
+	if b, err = json.Marshal(sequences); err != nil {
+		return 0, err
+	}
+	if err := d.writeFile(path, append(b, '\n')); err != nil {
+		return 0, err
+	}
+	return sequences[name], nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// With Options.Sharding set to n, each record is stored n directory levels
+// below its collection, in directories named by successive pairs of hex
+// digits of the SHA-256 of its (folded) name, e.g. "u/3f/a2/john.json" for
+// n = 2. Metadata, indexes, versions and checksums stay in the collection
+// directory.
+//
+// The layout is not recorded on disk, so a collection must always be opened
+// with the Sharding it was written with: records stored under another
+// layout are not found. To move an existing collection to a different
+// layout, DumpJSON it with the old setting and LoadJSON the dump into a
+// database opened with the new one.
+
+// shardDir returns the directory holding resource within the collection
+// directory dir.
+func (d *Driver) shardDir(dir, resource string) string {
+	if d.opts.Sharding <= 0 {
+		return dir
+	}
+
+	sum := sha256.Sum256([]byte(resource))
+	hash := hex.EncodeToString(sum[:])
+	for i := 0; i < d.opts.Sharding && 2*i+2 <= len(hash); i++ {
+		dir = filepath.Join(dir, hash[2*i:2*i+2])
+	}
+	return dir
+}
+
+// recordDirs returns the directories holding the records of collection: the
+// collection directory itself or, with Sharding, every existing shard
+// directory at the bottom level. It must be called with the collection lock
+// held.
+func (d *Driver) recordDirs(collection string) ([]string, error) {
+	dirs := []string{d.collectionDir(collection)}
+	for level := 0; level < d.opts.Sharding; level++ {
+		var next []string
+		for _, dir := range dirs {
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				return nil, err
+			}
+			for _, entry := range entries {
+				if entry.IsDir() && isShard(entry.Name()) {
+					next = append(next, filepath.Join(dir, entry.Name()))
+				}
+			}
+		}
+		dirs = next
+	}
+	return dirs, nil
+}
+
+// recordEntries returns the entries of every directory in recordDirs, sorted
+// by name. It must be called with the collection lock held.
+func (d *Driver) recordEntries(collection string) ([]os.DirEntry, error) {
+	if d.opts.Sharding <= 0 {
+		return os.ReadDir(d.collectionDir(collection))
+	}
+
+	dirs, err := d.recordDirs(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []os.DirEntry
+	for _, dir := range dirs {
+		e, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e...)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+func isShard(name string) bool {
+	if len(name) != 2 {
+		return false
+	}
+	for _, c := range name {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,64 @@
+package main
+
+// Operations in flight
+//
+// Every change goes through write, remove or removeCollection, and every
+// read of records through read, list or readAll, and those count
+// themselves. So do the exported methods that touch files directly, like
+// those for blobs, indexes, metadata and versions, and the operations
+// taking an OperationTimeout are counted as a whole. That lets Wait block
+// until they have all finished and Close refuse new ones. A plain
+// sync.WaitGroup doesn't fit, since new operations may start while Wait
+// is blocked.
+//
+// Counts nest, so an operation making several changes, like WriteMany or
+// Migrate, may fail with ErrClosed part way through if Close is called
+// while it runs; each change is either made in full or not at all.
+//
+// Only the driver's own work is counted: callbacks such as Scan functions,
+// Migrate functions and IDGenerator run between counted reads and writes,
+// and Stream sends outside them, so calling Wait from them or while a
+// Stream is undrained doesn't deadlock. Calling it while holding
+// LockCollection does if an operation is waiting for that lock.
+
+// begin counts a new operation, or fails with ErrClosed after Close.
+func (d *Driver) begin() error {
+	d.opsMutex.Lock()
+	defer d.opsMutex.Unlock()
+
+	if d.closing {
+		return ErrClosed
+	}
+	d.opsActive++
+	return nil
+}
+
+func (d *Driver) end() {
+	d.opsMutex.Lock()
+	defer d.opsMutex.Unlock()
+
+	d.opsActive--
+	if d.opsActive == 0 {
+		d.opsIdle.Broadcast()
+	}
+}
+
+// Wait blocks until no operation is in flight. Operations started while it
+// waits are waited for too; call Close first for a clean shutdown.
+func (d *Driver) Wait() {
+	d.opsMutex.Lock()
+	defer d.opsMutex.Unlock()
+
+	for d.opsActive > 0 {
+		d.opsIdle.Wait()
+	}
+}
+
+// shutdown makes new operations fail with ErrClosed and waits for the ones
+// in flight.
+func (d *Driver) shutdown() {
+	d.opsMutex.Lock()
+	d.closing = true
+	d.opsMutex.Unlock()
+	d.Wait()
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestClosedDriverRefusesChanges(t *testing.T) {
+	d := newTestDriver(t, nil)
+	for _, name := range []string{"john", "jane"} {
+		if err := d.Write("users", name, map[string][]string{"tags": {name}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	keep := func(current json.RawMessage) (json.RawMessage, error) { return current, nil }
+	for name, op := range map[string]func() error{
+		"Write":    func() error { return d.Write("users", "john", map[string]string{}) },
+		"WriteRaw": func() error { return d.WriteRaw("users", "john", []byte(`{}`)) },
+		"Insert": func() error {
+			_, err := d.Insert("users", map[string]string{})
+			return err
+		},
+		"UpdateField": func() error { return d.UpdateField("users", "john", "tags", keep) },
+		"Swap":        func() error { return d.Swap("users", "john", "jane") },
+		"WriteMany": func() error {
+			return d.WriteMany("users", []Entry{{Resource: "joe", Value: map[string]string{}}})
+		},
+		"Migrate": func() error {
+			_, err := d.Migrate("users", func(raw []byte) ([]byte, error) { return []byte(`{}`), nil })
+			return err
+		},
+		"DeleteWhere": func() error {
+			_, err := DeleteWhere(d, "users", func(map[string][]string) bool { return true })
+			return err
+		},
+		"AppendToArray":    func() error { return d.AppendToArray("users", "john", "x") },
+		"WriteBlob":        func() error { return d.WriteBlob("users", "avatar", strings.NewReader("x")) },
+		"CreateIndex":      func() error { return d.CreateIndex("users", "tags") },
+		"RenameCollection": func() error { return d.RenameCollection("users", "people") },
+		"Delete":           func() error { return d.Delete("users", "john") },
+		"DeleteCollection": func() error { return d.Delete("users", "") },
+		"NextSequence": func() error {
+			_, err := d.NextSequence("users")
+			return err
+		},
+	} {
+		if err := op(); !errors.Is(err, ErrClosed) {
+			t.Errorf("%v after Close: got %v, want ErrClosed", name, err)
+		}
+	}
+
+	var v map[string][]string
+	if err := d.Read("users", "john", &v); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Read after Close: got %v, want ErrClosed", err)
+	}
+}
+
+func TestClosedDriverRefusesReads(t *testing.T) {
+	d := newTestDriver(t, nil)
+	if err := d.Write("users", "john", map[string]int{"age": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.CreateSortedIndex("users", "age"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, op := range map[string]func() error{
+		"ReadAll":     func() error { return second(d.ReadAll("users")) },
+		"List":        func() error { return second(d.List("users")) },
+		"Count":       func() error { return second(d.Count("users")) },
+		"ApproxCount": func() error { return second(d.ApproxCount("users")) },
+		"Collections": func() error { return second(d.Collections()) },
+		"Scan": func() error {
+			return d.Scan("users", func(string, []byte) (bool, error) { return false, nil })
+		},
+		"Stream": func() error {
+			records, errs := d.Stream("users")
+			for range records {
+			}
+			return <-errs
+		},
+		"ReadRaw":    func() error { return second(d.ReadRaw("users", "john")) },
+		"ReadField":  func() error { return second(d.ReadField("users", "john", "age")) },
+		"RangeQuery": func() error { return second(d.RangeQuery("users", "age", "", "")) },
+		"History":    func() error { return second(d.History("users", "john")) },
+	} {
+		if err := op(); !errors.Is(err, ErrClosed) {
+			t.Errorf("%v after Close: got %v, want ErrClosed", name, err)
+		}
+	}
+}
+
+func TestWaitFromScan(t *testing.T) {
+	d := newTestDriver(t, nil)
+	if err := d.Write("users", "john", map[string]int{"age": 1}); err != nil {
+		t.Fatal(err)
+	}
+	err := noDeadlock(t, func() error {
+		return d.Scan("users", func(string, []byte) (bool, error) {
+			d.Wait()
+			return false, nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
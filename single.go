@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Single-file collections
+//
+// With Options.SingleFilePerCollection each collection is stored as one
+// JSON object, <collection>.json in the database directory, mapping resource
+// names to records. It suits many tiny records: there is one file per
+// collection instead of one per record. The cost is that every Write and
+// Delete decodes and rewrites the whole file under the collection lock, so
+// writes get slower as the collection grows. The file is still replaced
+// atomically, so reads remain lock-free and never see a partial update.
+//
+// Write, Read, Delete, List, Count, ReadAll and the methods built on them
+// support the mode. Features that keep per-record files or collection
+// directories, such as blobs, indexes, unique constraints, versions,
+// checksums, sharding, archives, Swap, ReadField, ReadModifiedBetween and
+// UseMmap, do not.
+
+func (d *Driver) singlePath(collection string) string {
+	return filepath.Join(d.dir, d.fold(collection)+".json")
+}
+
+// loadSingle returns the records of a single-file collection. A missing
+// collection fails with an error matching fs.ErrNotExist, like list.
+func (d *Driver) loadSingle(collection string) (map[string]json.RawMessage, error) {
+	b, err := os.ReadFile(d.singlePath(collection))
+	if err != nil {
+		return nil, err
+	}
+
+	var records map[string]json.RawMessage
+	if err := json.Unmarshal(b, &records); err != nil {
+		return nil, fmt.Errorf("invalid collection file %v: %v", collection, err)
+	}
+	return records, nil
+}
+
+// writeSingle must be called with the collection lock held.
+func (d *Driver) writeSingle(collection, resource string, b []byte) error {
+	records, err := d.loadSingle(collection)
+	if os.IsNotExist(err) {
+		records, err = make(map[string]json.RawMessage), nil
+	}
+	if err != nil {
+		return err
+	}
+
+	records[d.fold(resource)] = bytes.TrimSpace(b)
+	return d.saveSingle(collection, records)
+}
+
+// removeSingle must be called with the collection lock held.
+func (d *Driver) removeSingle(collection, resource string) error {
+	if resource == "" {
+		if err := os.Remove(d.singlePath(collection)); err != nil {
+			return err
+		}
+		d.unmirror(d.singlePath(collection))
+		return nil
+	}
+
+	records, err := d.loadSingle(collection)
+	if err != nil {
+		return err
+	}
+	delete(records, d.fold(resource))
+	return d.saveSingle(collection, records)
+}
+
+func (d *Driver) saveSingle(collection string, records map[string]json.RawMessage) error {
+	b, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	if !d.config(collection).Compact {
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, b, "", "\t"); err != nil {
+			return err
+		}
+		b = buf.Bytes()
+	}
+
+	path := d.singlePath(collection)
+	if err := d.checkSpace(d.dir, len(b)+1); err != nil {
+		return err
+	}
+	d.log.Debug("Writing collection: %s", path)
+	b = append(b, '\n')
+	if err := d.writeFile(path, b); err != nil {
+		return err
+	}
+	if err := d.noteWrite(path); err != nil {
+		return err
+	}
+	d.mirror(path, b)
+	return nil
+}
+
+func (d *Driver) readSingle(collection, resource string) ([]byte, error) {
+	records, err := d.loadSingle(collection)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %w", ErrNotFound, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	b, ok := records[d.fold(resource)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %v/%v", ErrNotFound, collection, resource)
+	}
+	return b, nil
+}
+
+func (d *Driver) listSingle(collection string) ([]string, error) {
+	records, err := d.loadSingle(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(records))
+	for name := range records {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
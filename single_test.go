@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestSingleFilePerCollection(t *testing.T) {
+	d := newTestDriver(t, &Options{SingleFilePerCollection: true})
+	for name, age := range map[string]int{"john": 30, "jane": 20, "joe": 10} {
+		if err := d.Write("users", name, map[string]int{"age": age}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	records, failed := d.ReadAllPartial("users")
+	if len(records) != 3 || len(failed) != 0 {
+		t.Fatalf("ReadAllPartial = %v, %v, want 3 records", records, failed)
+	}
+
+	deleted, err := DeleteWhere(d, "users", func(v map[string]int) bool { return v["age"] < 25 })
+	if err != nil || deleted != 2 {
+		t.Fatalf("DeleteWhere = %v, %v, want 2", deleted, err)
+	}
+	if names, err := d.List("users"); err != nil || len(names) != 1 || names[0] != "john" {
+		t.Fatalf("List = %v, %v, want [john]", names, err)
+	}
+
+	if err := d.Swap("users", "john", "jane"); err == nil {
+		t.Fatal("Swap succeeded with SingleFilePerCollection")
+	}
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// sortedEntry is one distinct value of a sorted index with the records
+// holding it.
+type sortedEntry struct {
+	Key       string
+	Numeric   bool `json:",omitempty"`
+	Resources []string
+}
+
+// sortedIndex is kept ordered by value: numbers first, in numeric order,
+// then strings in lexical order. Other JSON values are not indexed.
+type sortedIndex []sortedEntry
+
+// CreateSortedIndex registers an ordered index on a top-level numeric or
+// string field of the records in collection, used by RangeQuery. Like
+// CreateIndex it is maintained by Write and Delete and rebuilt by Reindex.
+func (d *Driver) CreateSortedIndex(collection, field string) error {
+	if collection == "" {
+		return fmt.Errorf("%w - unable to create index", ErrMissingCollection)
+	}
+	if field == "" {
+		return fmt.Errorf("missing field - unable to create index")
+	}
+	if err := validNames(collection, field); err != nil {
+		return err
+	}
+	if d.opts.ReadOnly {
+		return ErrReadOnly
+	}
+
+	if err := d.begin(); err != nil {
+		return err
+	}
+	defer d.end()
+
+	mutex := d.getOrCreateMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	meta := d.meta(collection)
+	for _, f := range meta.Sorted {
+		if f == field {
+			return nil
+		}
+	}
+
+	idx, err := d.buildSorted(collection, field)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(d.collectionDir(collection), d.dirMode); err != nil {
+		return err
+	}
+	if err := d.saveSorted(collection, field, idx); err != nil {
+		return err
+	}
+
+	meta.Sorted = append(meta.Sorted, field)
+	return d.saveMeta(collection, meta)
+}
+
+// RangeQuery returns the names of the records whose field, covered by a
+// sorted index, lies between min and max inclusive, in value order. An
+// empty bound is open. Numeric bounds select numeric values and string
+// bounds select string values; mixing the two is an error. With both bounds
+// empty every indexed record is returned, numbers first.
+func (d *Driver) RangeQuery(collection, field, min, max string) ([]string, error) {
+	if collection == "" {
+		return nil, fmt.Errorf("%w - unable to query index", ErrMissingCollection)
+	}
+	if err := validNames(collection, field); err != nil {
+		return nil, err
+	}
+
+	lo, hi := boundEntry(min), boundEntry(max)
+	if min != "" && max != "" && lo.Numeric != hi.Numeric {
+		return nil, fmt.Errorf("range bounds %q and %q are of different types", min, max)
+	}
+	numeric := lo.Numeric || hi.Numeric
+
+	if err := d.begin(); err != nil {
+		return nil, err
+	}
+	defer d.end()
+
+	mutex := d.getOrCreateMutex(collection)
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	if !d.hasSorted(collection, field) {
+		return nil, fmt.Errorf("no sorted index on %v.%v", collection, field)
+	}
+
+	idx, err := d.loadSorted(collection, field)
+	if err != nil {
+		return nil, err
+	}
+
+	start := 0
+	if min != "" {
+		start = sort.Search(len(idx), func(i int) bool { return compareEntries(idx[i], lo) >= 0 })
+	}
+
+	var names []string
+	for _, e := range idx[start:] {
+		if min == "" && max == "" {
+			names = append(names, e.Resources...)
+			continue
+		}
+		if e.Numeric != numeric {
+			if numeric {
+				break
+			}
+			continue
+		}
+		if max != "" && compareEntries(e, hi) > 0 {
+			break
+		}
+		names = append(names, e.Resources...)
+	}
+	return names, nil
+}
+
+// buildSorted must be called with the collection lock held.
+func (d *Driver) buildSorted(collection, field string) (sortedIndex, error) {
+	var idx sortedIndex
+	names, err := d.list(collection)
+	if os.IsNotExist(err) {
+		return idx, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range names {
+		b, err := d.read(collection, name)
+		if err != nil {
+			return nil, err
+		}
+		if e, ok := sortedKey(b, field); ok {
+			idx = idx.add(e, name)
+		}
+	}
+	return idx, nil
+}
+
+// updateSorted re-files resource in every sorted index of collection. A nil
+// b removes it. It must be called with the collection lock held.
+func (d *Driver) updateSorted(collection, resource string, b []byte) error {
+	for _, field := range d.meta(collection).Sorted {
+		idx, err := d.loadSorted(collection, field)
+		if err != nil {
+			return err
+		}
+
+		idx = idx.remove(resource)
+		if e, ok := sortedKey(b, field); ok {
+			idx = idx.add(e, resource)
+		}
+
+		if err := d.saveSorted(collection, field, idx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Driver) hasSorted(collection, field string) bool {
+	for _, f := range d.meta(collection).Sorted {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Driver) loadSorted(collection, field string) (sortedIndex, error) {
+	var idx sortedIndex
+	b, err := os.ReadFile(sortedPath(d.collectionDir(collection), field))
+	if os.IsNotExist(err) {
+		return idx, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &idx); err != nil {
+		return nil, fmt.Errorf("invalid sorted index %v.%v: %v", collection, field, err)
+	}
+	return idx, nil
+}
+
+func (d *Driver) saveSorted(collection, field string, idx sortedIndex) error {
+	if idx == nil {
+		idx = sortedIndex{}
+	}
+	b, err := json.Marshal(idx)
+	if err != nil {
+		return err
+	}
+	return d.writeFile(sortedPath(d.collectionDir(collection), field), append(b, byte('\n')))
+}
+
+func sortedPath(dir, field string) string {
+	return filepath.Join(dir, ".sorted."+field+".json")
+}
+
+func (idx sortedIndex) add(e sortedEntry, resource string) sortedIndex {
+	i := sort.Search(len(idx), func(i int) bool { return compareEntries(idx[i], e) >= 0 })
+	if i < len(idx) && compareEntries(idx[i], e) == 0 {
+		idx[i].Resources = append(idx[i].Resources, resource)
+		sort.Strings(idx[i].Resources)
+		return idx
+	}
+
+	e.Resources = []string{resource}
+	idx = append(idx, sortedEntry{})
+	copy(idx[i+1:], idx[i:])
+	idx[i] = e
+	return idx
+}
+
+func (idx sortedIndex) remove(resource string) sortedIndex {
+	out := idx[:0]
+	for _, e := range idx {
+		names := e.Resources[:0]
+		for _, name := range e.Resources {
+			if name != resource {
+				names = append(names, name)
+			}
+		}
+		if len(names) > 0 {
+			e.Resources = names
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// sortedKey returns the sorted index entry for the top-level field of the
+// JSON object b, if it is a number or a string.
+func sortedKey(b []byte, field string) (sortedEntry, bool) {
+	raw, err := decodeField(bytes.NewReader(b), field)
+	if err != nil {
+		return sortedEntry{}, false
+	}
+
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		return sortedEntry{}, false
+	}
+	if raw[0] == '"' {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return sortedEntry{}, false
+		}
+		return sortedEntry{Key: s}, true
+	}
+	if _, ok := new(big.Rat).SetString(string(raw)); ok {
+		return sortedEntry{Key: string(raw), Numeric: true}, true
+	}
+	return sortedEntry{}, false
+}
+
+func boundEntry(s string) sortedEntry {
+	if _, ok := new(big.Rat).SetString(s); ok {
+		return sortedEntry{Key: s, Numeric: true}
+	}
+	return sortedEntry{Key: s}
+}
+
+func compareEntries(a, b sortedEntry) int {
+	switch {
+	case a.Numeric && b.Numeric:
+		x, _ := new(big.Rat).SetString(a.Key)
+		y, _ := new(big.Rat).SetString(b.Key)
+		return x.Cmp(y)
+	case a.Numeric:
+		return -1
+	case b.Numeric:
+		return 1
+	}
+	return strings.Compare(a.Key, b.Key)
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRangeQuery(t *testing.T) {
+	d := newTestDriver(t, nil)
+	for name, v := range map[string]interface{}{"five": 5, "ten": 10, "b": "b", "d": "d"} {
+		if err := d.Write("things", name, map[string]interface{}{"v": v}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.CreateSortedIndex("things", "v"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		min, max string
+		want     []string
+	}{
+		{"", "", []string{"five", "ten", "b", "d"}},
+		{"6", "", []string{"ten"}},
+		{"", "6", []string{"five"}},
+		{"a", "c", []string{"b"}},
+		{"c", "", []string{"d"}},
+	} {
+		got, err := d.RangeQuery("things", "v", tc.min, tc.max)
+		if err != nil || !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("RangeQuery(%q, %q) = %v, %v, want %v", tc.min, tc.max, got, err, tc.want)
+		}
+	}
+	if _, err := d.RangeQuery("things", "v", "1", "z"); err == nil {
+		t.Error("mixed bounds accepted")
+	}
+}
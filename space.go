@@ -0,0 +1,41 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// With Options.MinFreeBytes every write first checks the free space of the
+// filesystem it goes to, and fails with ErrInsufficientSpace, before any
+// temp file is created, when writing the record would leave less than
+// MinFreeBytes available. Blobs, whose size isn't known up front, only
+// check that the threshold isn't crossed already.
+//
+// Free space is read with statfs(2) on Linux, macOS, FreeBSD and DragonFly
+// and GetDiskFreeSpaceEx on Windows. Elsewhere the check is skipped with a
+// warning and writes go ahead. Concurrent writers, including other
+// processes, can still use up the space between the check and the write.
+
+var errNoFreeSpace = errors.New("free space check not supported")
+
+// checkSpace fails if writing n bytes in dir would leave less than
+// Options.MinFreeBytes free.
+func (d *Driver) checkSpace(dir string, n int) error {
+	if d.opts.MinFreeBytes <= 0 {
+		return nil
+	}
+
+	free, err := freeBytes(dir)
+	if err == errNoFreeSpace {
+		d.log.Warn("Free space check is not supported on this platform")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if free < uint64(n) || free-uint64(n) < uint64(d.opts.MinFreeBytes) {
+		return fmt.Errorf("%w - writing %v bytes to %v would leave less than %v of %v bytes free", ErrInsufficientSpace, n, dir, d.opts.MinFreeBytes, free)
+	}
+	return nil
+}
@@ -0,0 +1,7 @@
+//go:build !(linux || darwin || freebsd || dragonfly || windows)
+
+package main
+
+func freeBytes(dir string) (uint64, error) {
+	return 0, errNoFreeSpace
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// StaleTmpPolicy decides what Read does when it finds a record's .tmp file
+// newer than the record itself, the trace of a write interrupted between
+// writing the temp file and renaming it into place. In every case the
+// committed record is what Read returns unless the temp file is promoted.
+type StaleTmpPolicy int
+
+const (
+	// IgnoreStaleTmp returns the committed record: the interrupted write is
+	// lost, which is the safe default.
+	IgnoreStaleTmp StaleTmpPolicy = iota
+	// WarnStaleTmp does the same but logs a warning.
+	WarnStaleTmp
+	// PromoteStaleTmp writes the temp file's contents over the record,
+	// completing the interrupted write, if it holds valid JSON.
+	PromoteStaleTmp
+)
+
+// checkStaleTmp applies the stale temp file policy to a record. It takes the
+// collection lock to promote, so it must be called without it.
+func (d *Driver) checkStaleTmp(collection, resource string) {
+	path := d.recordPath(collection, resource) + ".json"
+	if !newerTmp(path) {
+		return
+	}
+
+	if d.opts.StaleTmp == WarnStaleTmp || d.opts.ReadOnly {
+		d.log.Warn("Found newer temp file for %s/%s, ignoring it", collection, resource)
+		return
+	}
+
+	// A Write in progress holds the lock; once we have it, any temp file
+	// left is really stale.
+	mutex := d.getOrCreateMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if !newerTmp(path) {
+		return
+	}
+
+	b, err := os.ReadFile(path + ".tmp")
+	if err != nil || !json.Valid(b) {
+		d.log.Warn("Found newer temp file for %s/%s, but it is not valid JSON", collection, resource)
+		return
+	}
+
+	unlock, err := d.lockFile(collection)
+	if err != nil {
+		d.log.Error("Failed to promote temp file: %v", err)
+		return
+	}
+	defer unlock()
+
+	// Promote it like any other write, so that checksums, replicas,
+	// versions and indexes follow.
+	d.log.Warn("Promoting newer temp file for %s/%s", collection, resource)
+	if err := d.applyWrite(collection, resource, b); err != nil {
+		d.log.Error("Failed to promote temp file: %v", err)
+		return
+	}
+	if err := os.Remove(path + ".tmp"); err != nil && !os.IsNotExist(err) {
+		d.log.Error("Failed to remove promoted temp file: %v", err)
+	}
+}
+
+func newerTmp(path string) bool {
+	tmp, err := os.Stat(path + ".tmp")
+	if err != nil {
+		return false
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return true
+	}
+	return tmp.ModTime().After(fi.ModTime())
+}
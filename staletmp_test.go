@@ -0,0 +1,86 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStaleTmp(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		policy StaleTmpPolicy
+		tmp    string
+		want   string
+		warned bool
+	}{
+		{"ignore", IgnoreStaleTmp, `{"v":"new"}`, "old", false},
+		{"warn", WarnStaleTmp, `{"v":"new"}`, "old", true},
+		{"promote", PromoteStaleTmp, `{"v":"new"}`, "new", true},
+		{"promote invalid", PromoteStaleTmp, `{"v":`, "old", true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			log := &recordingLogger{match: "newer temp file"}
+			d := newTestDriver(t, &Options{Logger: log, StaleTmp: tc.policy})
+			if err := d.Write("users", "john", map[string]string{"v": "old"}); err != nil {
+				t.Fatal(err)
+			}
+
+			// Leave the temp file of a write interrupted after the record
+			// was last committed.
+			path := d.recordPath("users", "john") + ".json"
+			if err := os.WriteFile(path+".tmp", []byte(tc.tmp), 0644); err != nil {
+				t.Fatal(err)
+			}
+			later := time.Now().Add(time.Minute)
+			if err := os.Chtimes(path+".tmp", later, later); err != nil {
+				t.Fatal(err)
+			}
+
+			var v map[string]string
+			if err := d.Read("users", "john", &v); err != nil {
+				t.Fatal(err)
+			}
+			if v["v"] != tc.want {
+				t.Errorf("Read = %v, want %v", v["v"], tc.want)
+			}
+			if _, err := os.Stat(path + ".tmp"); os.IsNotExist(err) != (tc.want == "new") {
+				t.Errorf("temp file left = %v, want %v", err == nil, tc.want == "old")
+			}
+			if warned := len(log.lines) > 0; warned != tc.warned {
+				t.Errorf("warned = %v (%q), want %v", warned, log.lines, tc.warned)
+			}
+		})
+	}
+}
+
+func TestPromoteStaleTmpKeepsChecksumAndReplicas(t *testing.T) {
+	replica := t.TempDir()
+	d := newTestDriver(t, &Options{StaleTmp: PromoteStaleTmp, Checksum: true, ReplicaDirs: []string{replica}})
+	if err := d.Write("users", "john", map[string]string{"v": "old"}); err != nil {
+		t.Fatal(err)
+	}
+	path := d.recordPath("users", "john") + ".json"
+	if err := os.WriteFile(path+".tmp", []byte(`{"v":"new"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path+".tmp", later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		var v map[string]string
+		if err := d.Read("users", "john", &v); err != nil || v["v"] != "new" {
+			t.Fatalf("Read %d = %v, %v, want new", i, v, err)
+		}
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temp file left after promotion: %v", err)
+	}
+	b, err := os.ReadFile(filepath.Join(replica, "users", "john.json"))
+	if err != nil || string(b) != `{"v":"new"}` {
+		t.Errorf("replica = %s, %v, want the promoted record", b, err)
+	}
+}
@@ -0,0 +1,18 @@
+package main
+
+// Store is the core record API of Driver, for code that wants to depend on
+// an interface and substitute a fake in its tests. *Driver is the canonical
+// implementation; implementations must return errors matching ErrNotFound
+// for missing records and ErrMissingCollection and ErrMissingResource for
+// empty names, as Driver does.
+type Store interface {
+	Write(collection, resource string, v interface{}) error
+	Read(collection, resource string, v interface{}) error
+	ReadAll(collection string) ([]string, error)
+	Delete(collection, resource string) error
+	List(collection string) ([]string, error)
+	Count(collection string) (int, error)
+	Collections() ([]string, error)
+}
+
+var _ Store = (*Driver)(nil)
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Record is a raw record delivered by Stream.
+type Record struct {
+	Resource string
+	Data     []byte
+}
+
+// Stream sends every record in collection on the returned channel as it is
+// read, in name order, so a slow consumer holds back reading rather than
+// the collection being loaded at once. The names are listed up front; each
+// record is then read like Read, without holding the collection lock, and
+// records deleted meanwhile are skipped. Reading stops at the first failure,
+// which is sent on the error channel. Both channels are closed when the
+// stream ends; the error channel has room for that one error, so it can be
+// checked after draining the records. Consumers must drain the records
+// channel, or the goroutine reading them is never released.
+func (d *Driver) Stream(collection string) (<-chan Record, <-chan error) {
+	records := make(chan Record)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(errs)
+		defer close(records)
+
+		if collection == "" {
+			errs <- fmt.Errorf("%w - unable to read", ErrMissingCollection)
+			return
+		}
+		if err := validNames(collection); err != nil {
+			errs <- err
+			return
+		}
+
+		mutex := d.getOrCreateMutex(collection)
+		mutex.RLock()
+		names, err := d.list(collection)
+		mutex.RUnlock()
+		if err != nil {
+			errs <- err
+			return
+		}
+
+		for _, name := range names {
+			b, err := d.readThrough(collection, name)
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				errs <- err
+				return
+			}
+			records <- Record{Resource: name, Data: b}
+		}
+	}()
+
+	return records, errs
+}
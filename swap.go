@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Swap exchanges the contents of two existing records under the collection
+// lock. Each is written like any other record, so versions, replicas,
+// checksums and indexes follow. With the WAL enabled both writes are logged
+// before either is made, so Replay completes a swap cut short by a crash;
+// without it a crash between the two can leave both records with the
+// contents of the second.
+func (d *Driver) Swap(collection, resourceA, resourceB string) error {
+	if collection == "" {
+		return fmt.Errorf("%w - unable to swap", ErrMissingCollection)
+	}
+	if resourceA == "" || resourceB == "" {
+		return fmt.Errorf("%w - unable to swap (no name)", ErrMissingResource)
+	}
+	if err := validNames(collection, resourceA, resourceB); err != nil {
+		return err
+	}
+	if d.opts.SingleFilePerCollection {
+		return fmt.Errorf("Swap is not supported with SingleFilePerCollection")
+	}
+	if d.fold(resourceA) == d.fold(resourceB) {
+		return nil
+	}
+	if d.opts.ReadOnly {
+		return ErrReadOnly
+	}
+	if err := d.begin(); err != nil {
+		return err
+	}
+	defer d.end()
+
+	mutex := d.getOrCreateMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	a, err := d.read(collection, resourceA)
+	if err != nil {
+		return err
+	}
+	b, err := d.read(collection, resourceB)
+	if err != nil {
+		return err
+	}
+
+	if d.keyField != "" {
+		if a, err = d.rekey(collection, resourceA, resourceB, a); err != nil {
+			return err
+		}
+		if b, err = d.rekey(collection, resourceB, resourceA, b); err != nil {
+			return err
+		}
+	}
+
+	unlock, err := d.lockFile(collection)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if d.useWAL {
+		for _, e := range []walEntry{
+			{Op: walWrite, Collection: collection, Resource: resourceA, Data: b},
+			{Op: walWrite, Collection: collection, Resource: resourceB, Data: a},
+		} {
+			id, err := d.logWAL(e)
+			if err != nil {
+				return err
+			}
+			defer d.checkpoint(id)
+		}
+	}
+
+	// Each record holds the unique values the other is about to take, so
+	// both leave the unique indexes before either is written.
+	for _, resource := range []string{resourceA, resourceB} {
+		if err := d.updateUnique(collection, d.fold(resource), nil); err != nil {
+			return err
+		}
+	}
+
+	d.log.Debug("Swapping %s/%s and %s/%s", collection, resourceA, collection, resourceB)
+	if err := d.applyWrite(collection, resourceA, b); err != nil {
+		return err
+	}
+	return d.applyWrite(collection, resourceB, a)
+}
+
+// rekey re-marshals record b, stored as from, for storage under to.
+func (d *Driver) rekey(collection, from, to string, b []byte) ([]byte, error) {
+	b, err := stripKey(b, d.keyField, d.fold(from))
+	if err != nil {
+		return nil, err
+	}
+	return d.marshal(collection, to, json.RawMessage(b))
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSwap(t *testing.T) {
+	replica := t.TempDir()
+	d := newTestDriver(t, &Options{KeepVersions: 3, ReplicaDirs: []string{replica}})
+	if err := d.SetUniqueConstraint("users", []string{"email"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Write("users", "john", map[string]string{"email": "john@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Write("users", "jane", map[string]string{"email": "jane@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.Swap("users", "john", "jane"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{"john": "jane@example.com", "jane": "john@example.com"}
+	for name, email := range want {
+		var v map[string]string
+		if err := d.Read("users", name, &v); err != nil || v["email"] != email {
+			t.Errorf("%v = %v, %v, want %v", name, v, err, email)
+		}
+		if versions, err := d.History("users", name); err != nil || len(versions) != 1 {
+			t.Errorf("History(%v) = %v, %v, want one version", name, versions, err)
+		}
+		primary, _ := d.ReadRaw("users", name)
+		mirrored, err := os.ReadFile(filepath.Join(replica, "users", name+".json"))
+		if err != nil || string(mirrored) != string(primary) {
+			t.Errorf("replica of %v = %s, %v, want %s", name, mirrored, err, primary)
+		}
+	}
+
+	// The unique index follows the swap.
+	if err := d.Write("users", "joe", map[string]string{"email": "jane@example.com"}); err == nil {
+		t.Error("duplicate email accepted after Swap")
+	}
+	if err := d.Write("users", "john", map[string]string{"email": "jane@example.com"}); err != nil {
+		t.Errorf("rewriting john: %v", err)
+	}
+}
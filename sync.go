@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// noteWrite records that path was written and, with Options.SyncEvery set,
+// flushes the last SyncEvery written records and their directories to disk
+// once that many have accumulated. Up to SyncEvery-1 writes can therefore
+// be lost in a power failure, in exchange for far fewer fsyncs.
+func (d *Driver) noteWrite(path string) error {
+	if d.opts.SyncEvery <= 0 {
+		return nil
+	}
+
+	d.mutex.Lock()
+	d.unsynced = append(d.unsynced, path)
+	full := len(d.unsynced) >= d.opts.SyncEvery
+	d.mutex.Unlock()
+
+	if !full {
+		return nil
+	}
+	return d.syncWrites()
+}
+
+// syncWrites fsyncs the records noted by noteWrite since the last sync.
+func (d *Driver) syncWrites() error {
+	d.mutex.Lock()
+	paths := d.unsynced
+	d.unsynced = nil
+	d.mutex.Unlock()
+
+	if len(paths) == 0 {
+		return nil
+	}
+
+	d.log.Debug("Syncing %d writes", len(paths))
+	dirs := make(map[string]bool)
+	for _, path := range paths {
+		if err := syncPath(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		dirs[filepath.Dir(path)] = true
+	}
+
+	// Windows cannot fsync a directory, nor does it need to.
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	for dir := range dirs {
+		if err := syncPath(dir); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func syncPath(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Sync()
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// RegisterType records the Go type of the records in collection, taken from
+// proto, for helpers such as ReadAllTyped that decode without a type
+// parameter. Registering again replaces the type. The registry is guarded
+// by the driver's mutex, so registration and lookups are safe from any
+// goroutine, though types are normally registered once at startup.
+func (d *Driver) RegisterType(collection string, proto interface{}) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.types == nil {
+		d.types = make(map[string]reflect.Type)
+	}
+	d.types[d.fold(collection)] = reflect.TypeOf(proto)
+}
+
+// ReadAllTyped decodes every record in collection into a new value of the
+// type registered with RegisterType, in name order. The values have the
+// registered type itself, so registering a pointer such as &User{} yields
+// *User values and registering User{} yields User values.
+func (d *Driver) ReadAllTyped(collection string) ([]interface{}, error) {
+	if collection == "" {
+		return nil, fmt.Errorf("%w - unable to read", ErrMissingCollection)
+	}
+	if err := validNames(collection); err != nil {
+		return nil, err
+	}
+
+	d.mutex.Lock()
+	t := d.types[d.fold(collection)]
+	d.mutex.Unlock()
+	if t == nil {
+		return nil, fmt.Errorf("no type registered for collection %v", collection)
+	}
+
+	mutex := d.getOrCreateMutex(collection)
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	names, err := d.list(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]interface{}, 0, len(names))
+	for _, name := range names {
+		b, err := d.read(collection, name)
+		if err != nil {
+			return nil, err
+		}
+
+		elem := t
+		if t.Kind() == reflect.Pointer {
+			elem = t.Elem()
+		}
+		ptr := reflect.New(elem)
+		if err := d.decode(collection, name, b, ptr.Interface()); err != nil {
+			return nil, fmt.Errorf("%v/%v: %w", collection, name, err)
+		}
+
+		if t.Kind() == reflect.Pointer {
+			values = append(values, ptr.Interface())
+		} else {
+			values = append(values, ptr.Elem().Interface())
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// uniqueIndex maps a tuple of field values to the record holding it.
+type uniqueIndex map[string]string
+
+// SetUniqueConstraint makes Write reject a record whose values for fields
+// are all equal to those of another record in collection, returning
+// ErrConstraintViolation. Rewriting a record with its own values is not a
+// conflict, so updates that keep or change the tuple work as expected.
+// Records missing any of the fields are not constrained. It fails if the
+// existing records already violate the constraint.
+func (d *Driver) SetUniqueConstraint(collection string, fields []string) error {
+	if collection == "" {
+		return fmt.Errorf("%w - unable to add constraint", ErrMissingCollection)
+	}
+	if len(fields) == 0 {
+		return fmt.Errorf("missing fields - unable to add constraint")
+	}
+	if err := validNames(append([]string{collection}, fields...)...); err != nil {
+		return err
+	}
+	if d.opts.ReadOnly {
+		return ErrReadOnly
+	}
+
+	if err := d.begin(); err != nil {
+		return err
+	}
+	defer d.end()
+
+	mutex := d.getOrCreateMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	meta := d.meta(collection)
+	for _, existing := range meta.Unique {
+		if uniqueName(existing) == uniqueName(fields) {
+			return nil
+		}
+	}
+
+	idx, err := d.buildUnique(collection, fields)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(d.collectionDir(collection), d.dirMode); err != nil {
+		return err
+	}
+	if err := d.saveUnique(collection, fields, idx); err != nil {
+		return err
+	}
+
+	meta.Unique = append(meta.Unique, append([]string(nil), fields...))
+	return d.saveMeta(collection, meta)
+}
+
+// buildUnique must be called with the collection lock held.
+func (d *Driver) buildUnique(collection string, fields []string) (uniqueIndex, error) {
+	idx := make(uniqueIndex)
+	names, err := d.list(collection)
+	if os.IsNotExist(err) {
+		return idx, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range names {
+		b, err := d.read(collection, name)
+		if err != nil {
+			return nil, err
+		}
+
+		key, ok := tupleKey(b, fields)
+		if !ok {
+			continue
+		}
+		if other, dup := idx[key]; dup {
+			return nil, fmt.Errorf("%w: %v and %v share %v", ErrConstraintViolation, other, name, uniqueName(fields))
+		}
+		idx[key] = name
+	}
+	return idx, nil
+}
+
+// checkUnique must be called with the collection lock held.
+func (d *Driver) checkUnique(collection, resource string, b []byte) error {
+	for _, fields := range d.meta(collection).Unique {
+		key, ok := tupleKey(b, fields)
+		if !ok {
+			continue
+		}
+
+		idx, err := d.loadUnique(collection, fields)
+		if err != nil {
+			return err
+		}
+		if other, dup := idx[key]; dup && other != resource {
+			return fmt.Errorf("%w: %v already has these %v", ErrConstraintViolation, other, uniqueName(fields))
+		}
+	}
+	return nil
+}
+
+// updateUnique re-files resource in every unique index of collection. A nil
+// b removes it. It must be called with the collection lock held.
+func (d *Driver) updateUnique(collection, resource string, b []byte) error {
+	for _, fields := range d.meta(collection).Unique {
+		idx, err := d.loadUnique(collection, fields)
+		if err != nil {
+			return err
+		}
+
+		for key, name := range idx {
+			if name == resource {
+				delete(idx, key)
+			}
+		}
+		if key, ok := tupleKey(b, fields); ok {
+			idx[key] = resource
+		}
+
+		if err := d.saveUnique(collection, fields, idx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Driver) loadUnique(collection string, fields []string) (uniqueIndex, error) {
+	idx := make(uniqueIndex)
+	b, err := os.ReadFile(uniquePath(d.collectionDir(collection), fields))
+	if os.IsNotExist(err) {
+		return idx, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &idx); err != nil {
+		return nil, fmt.Errorf("invalid unique index %v.%v: %v", collection, uniqueName(fields), err)
+	}
+	return idx, nil
+}
+
+func (d *Driver) saveUnique(collection string, fields []string, idx uniqueIndex) error {
+	b, err := json.Marshal(idx)
+	if err != nil {
+		return err
+	}
+	return d.writeFile(uniquePath(d.collectionDir(collection), fields), append(b, byte('\n')))
+}
+
+func uniquePath(dir string, fields []string) string {
+	return filepath.Join(dir, ".unique."+uniqueName(fields)+".json")
+}
+
+func uniqueName(fields []string) string {
+	return strings.Join(fields, "+")
+}
+
+// tupleKey returns the combined key of fields in the JSON object b, or false
+// if any of them is missing.
+func tupleKey(b []byte, fields []string) (string, bool) {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return "", false
+	}
+
+	values := make([]string, len(fields))
+	for i, field := range fields {
+		raw, ok := obj[field]
+		if !ok {
+			return "", false
+		}
+		values[i] = rawKey(raw)
+	}
+
+	key, _ := json.Marshal(values)
+	return string(key), true
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// versionTime is the timestamp format used in archived version file names.
+const versionTime = "20060102T150405.000000000Z"
+
+// VersionInfo describes one archived version of a record.
+type VersionInfo struct {
+	// Version numbers increase with every archived write and are never
+	// reused, so they stay valid while older versions are pruned.
+	Version int
+	Time    time.Time
+	Size    int64
+}
+
+// History returns the archived versions of a record kept under
+// Options.KeepVersions, oldest first. The current contents are not
+// included. See Revert to restore one.
+func (d *Driver) History(collection, resource string) ([]VersionInfo, error) {
+	if collection == "" {
+		return nil, fmt.Errorf("%w - unable to read history", ErrMissingCollection)
+	}
+	if resource == "" {
+		return nil, fmt.Errorf("%w - unable to read history (no name)", ErrMissingResource)
+	}
+	if err := validNames(collection, resource); err != nil {
+		return nil, err
+	}
+
+	if err := d.begin(); err != nil {
+		return nil, err
+	}
+	defer d.end()
+
+	mutex := d.getOrCreateMutex(collection)
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	versions, _, err := d.versions(collection, resource)
+	return versions, err
+}
+
+// ReadVersion decodes an archived version of a record, as numbered by
+// History, into v.
+func (d *Driver) ReadVersion(collection, resource string, version int, v interface{}) error {
+	if collection == "" {
+		return fmt.Errorf("%w - unable to read version", ErrMissingCollection)
+	}
+	if resource == "" {
+		return fmt.Errorf("%w - unable to read version (no name)", ErrMissingResource)
+	}
+	if err := validNames(collection, resource); err != nil {
+		return err
+	}
+	if err := checkTarget(v); err != nil {
+		return err
+	}
+
+	if err := d.begin(); err != nil {
+		return err
+	}
+	defer d.end()
+
+	mutex := d.getOrCreateMutex(collection)
+	mutex.RLock()
+	b, err := d.readVersion(collection, resource, version)
+	mutex.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	if d.keyField != "" {
+		if b, err = stripKey(b, d.keyField, d.fold(resource)); err != nil {
+			return err
+		}
+	}
+	return json.Unmarshal(b, v)
+}
+
+// readVersion must be called with the collection lock held.
+func (d *Driver) readVersion(collection, resource string, version int) ([]byte, error) {
+	versions, files, err := d.versions(collection, resource)
+	if err != nil {
+		return nil, err
+	}
+	for i, info := range versions {
+		if info.Version == version {
+			return os.ReadFile(files[i])
+		}
+	}
+	return nil, fmt.Errorf("%w: version %d of %v/%v", ErrNotFound, version, collection, resource)
+}
+
+// archive stores the current contents of a record as a new version before
+// they are replaced by b, pruning all but the newest KeepVersions. It must
+// be called with the collection lock held.
+func (d *Driver) archive(collection, resource string, b []byte) error {
+	current, err := os.ReadFile(d.recordPath(collection, resource) + ".json")
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(current, b) {
+		return nil
+	}
+
+	versions, files, err := d.versions(collection, resource)
+	if err != nil {
+		return err
+	}
+
+	next := 1
+	if len(versions) > 0 {
+		next = versions[len(versions)-1].Version + 1
+	}
+
+	dir := d.versionDir(collection, resource)
+	if err := os.MkdirAll(dir, d.dirMode); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%06d-%s.json", next, d.now().UTC().Format(versionTime))
+	if err := d.writeFile(filepath.Join(dir, name), current); err != nil {
+		return err
+	}
+
+	for len(files) >= d.opts.KeepVersions {
+		d.log.Debug("Pruning version: %s", files[0])
+		if err := os.Remove(files[0]); err != nil {
+			return err
+		}
+		files = files[1:]
+	}
+	return nil
+}
+
+// versions returns the archived versions of a record with their paths,
+// oldest first. It must be called with the collection lock held.
+func (d *Driver) versions(collection, resource string) ([]VersionInfo, []string, error) {
+	dir := d.versionDir(collection, resource)
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var versions []VersionInfo
+	var files []string
+	for _, entry := range entries {
+		info, ok := parseVersion(entry.Name())
+		if !ok || entry.IsDir() {
+			continue
+		}
+		if fi, err := entry.Info(); err == nil {
+			info.Size = fi.Size()
+		}
+		versions = append(versions, info)
+		files = append(files, filepath.Join(dir, entry.Name()))
+	}
+
+	sort.Sort(byVersion{versions, files})
+	return versions, files, nil
+}
+
+func (d *Driver) versionDir(collection, resource string) string {
+	return filepath.Join(d.collectionDir(collection), ".versions", d.fold(resource))
+}
+
+func parseVersion(name string) (VersionInfo, bool) {
+	seq, stamp, ok := strings.Cut(strings.TrimSuffix(name, ".json"), "-")
+	if !ok || !strings.HasSuffix(name, ".json") {
+		return VersionInfo{}, false
+	}
+	n, err := strconv.Atoi(seq)
+	if err != nil {
+		return VersionInfo{}, false
+	}
+	t, err := time.Parse(versionTime, stamp)
+	if err != nil {
+		return VersionInfo{}, false
+	}
+	return VersionInfo{Version: n, Time: t}, true
+}
+
+type byVersion struct {
+	versions []VersionInfo
+	files    []string
+}
+
+func (s byVersion) Len() int           { return len(s.versions) }
+func (s byVersion) Less(i, j int) bool { return s.versions[i].Version < s.versions[j].Version }
+func (s byVersion) Swap(i, j int) {
+	s.versions[i], s.versions[j] = s.versions[j], s.versions[i]
+	s.files[i], s.files[j] = s.files[j], s.files[i]
+}
+
+// Revert makes an archived version of a record, as numbered by History, its
+// current contents again. The contents it replaces are archived like any
+// other write, so a Revert can itself be reverted.
+func (d *Driver) Revert(collection, resource string, version int) error {
+	if collection == "" {
+		return fmt.Errorf("%w - unable to revert", ErrMissingCollection)
+	}
+	if resource == "" {
+		return fmt.Errorf("%w - unable to revert (no name)", ErrMissingResource)
+	}
+	if err := validNames(collection, resource); err != nil {
+		return err
+	}
+
+	mutex := d.getOrCreateMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	b, err := d.readVersion(collection, resource, version)
+	if err != nil {
+		return err
+	}
+	return d.write(collection, resource, b)
+}
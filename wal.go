@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const walFile = ".wal"
+
+const (
+	walWrite  = "write"
+	walDelete = "delete"
+)
+
+// walEntry is one line of the write-ahead log: either an operation, or a
+// Done marker recording that the operation with that ID has completed.
+type walEntry struct {
+	ID         int64           `json:",omitempty"`
+	Op         string          `json:",omitempty"`
+	Collection string          `json:",omitempty"`
+	Resource   string          `json:",omitempty"`
+	Data       json.RawMessage `json:",omitempty"`
+	Done       int64           `json:",omitempty"`
+}
+
+func (d *Driver) openWAL() error {
+	f, err := os.OpenFile(filepath.Join(d.dir, walFile), os.O_RDWR|os.O_CREATE|os.O_APPEND, d.fileMode)
+	if err != nil {
+		return err
+	}
+
+	d.walMutex.Lock()
+	d.wal = f
+	d.walMutex.Unlock()
+	return d.Replay()
+}
+
+func (d *Driver) closeWAL() error {
+	d.walMutex.Lock()
+	defer d.walMutex.Unlock()
+
+	if d.wal == nil {
+		return nil
+	}
+	err := d.wal.Close()
+	d.wal = nil
+	return err
+}
+
+// Replay re-applies the operations in the write-ahead log that have no Done
+// marker, i.e. ones interrupted by a crash, and then checkpoints the log.
+// New calls it when Options.WAL is set, before the Driver is returned; it
+// must not run concurrently with other operations, whose in-flight entries
+// it would apply a second time.
+func (d *Driver) Replay() error {
+	if !d.useWAL {
+		return fmt.Errorf("write-ahead log is not enabled")
+	}
+
+	if err := d.begin(); err != nil {
+		return err
+	}
+	defer d.end()
+
+	pending, err := d.pendingWAL()
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		d.log.Info("Replaying %d write-ahead log entries", len(pending))
+	}
+
+	for _, e := range pending {
+		err := d.replayEntry(e)
+		d.checkpoint(e.ID)
+		if err != nil {
+			return fmt.Errorf("unable to replay %v %v/%v: %v", e.Op, e.Collection, e.Resource, err)
+		}
+	}
+	return nil
+}
+
+// pendingWAL reads the log and returns the entries without a Done marker,
+// counting them as in flight until they are checkpointed.
+func (d *Driver) pendingWAL() ([]walEntry, error) {
+	d.walMutex.Lock()
+	defer d.walMutex.Unlock()
+
+	if d.wal == nil {
+		return nil, ErrClosed
+	}
+	if _, err := d.wal.Seek(0, 0); err != nil {
+		return nil, err
+	}
+
+	var entries []walEntry
+	done := make(map[int64]bool)
+	scanner := bufio.NewScanner(d.wal)
+	scanner.Buffer(nil, 64<<20)
+	for scanner.Scan() {
+		var e walEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			// A torn last line: its operation was never applied.
+			d.log.Warn("Skipping unreadable write-ahead log entry: %v", err)
+			continue
+		}
+		if e.Done != 0 {
+			done[e.Done] = true
+			continue
+		}
+		entries = append(entries, e)
+		if e.ID >= d.walNext {
+			d.walNext = e.ID + 1
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var pending []walEntry
+	for _, e := range entries {
+		if !done[e.ID] {
+			pending = append(pending, e)
+		}
+	}
+	d.walPending += len(pending)
+	return pending, nil
+}
+
+func (d *Driver) replayEntry(e walEntry) error {
+	mutex := d.getOrCreateMutex(e.Collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	switch e.Op {
+	case walWrite:
+		b, err := d.marshal(e.Collection, e.Resource, e.Data)
+		if err != nil {
+			return err
+		}
+		return d.applyWrite(e.Collection, e.Resource, b)
+	case walDelete:
+		err := d.applyRemove(e.Collection, e.Resource)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	return fmt.Errorf("unknown operation %q", e.Op)
+}
+
+// logWAL durably appends e to the log and returns its ID. Every call must be
+// followed by checkpoint once the operation is applied or has failed.
+func (d *Driver) logWAL(e walEntry) (int64, error) {
+	d.walMutex.Lock()
+	defer d.walMutex.Unlock()
+
+	if d.wal == nil {
+		return 0, ErrClosed
+	}
+	if d.walNext == 0 {
+		d.walNext = 1
+	}
+	e.ID = d.walNext
+
+	if err := d.appendWAL(e); err != nil {
+		return 0, err
+	}
+	if err := d.wal.Sync(); err != nil {
+		return 0, err
+	}
+
+	d.walNext++
+	d.walPending++
+	return e.ID, nil
+}
+
+// checkpoint marks the entry id as done and truncates the log once no
+// operation is in flight.
+func (d *Driver) checkpoint(id int64) {
+	d.walMutex.Lock()
+	defer d.walMutex.Unlock()
+
+	d.walPending--
+	if d.wal == nil {
+		return
+	}
+	if d.walPending == 0 {
+		if err := d.wal.Truncate(0); err != nil {
+			d.log.Error("Failed to truncate write-ahead log: %v", err)
+		}
+		return
+	}
+
+	if err := d.appendWAL(walEntry{Done: id}); err != nil {
+		d.log.Error("Failed to checkpoint write-ahead log: %v", err)
+	}
+}
+
+func (d *Driver) appendWAL(e walEntry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = d.wal.Write(append(b, byte('\n')))
+	return err
+}
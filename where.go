@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Where returns the names of the records in collection whose top-level
+// field satisfies op against value. Supported operators are eq, ne, gt,
+// ge, lt, le and contains.
+//
+// value is always given as a string and coerced to the field's JSON type:
+//   - numbers compare numerically (exactly, without float rounding) when
+//     value parses as a number; otherwise the record doesn't match
+//   - strings compare lexically; contains tests for a substring
+//   - booleans and null support eq and ne against "true", "false", "null"
+//   - arrays support contains, matching an element equal to value
+//   - objects never match
+//
+// Records without the field never match, not even with ne.
+func (d *Driver) Where(collection, field, op, value string) ([]string, error) {
+	switch op {
+	case "eq", "ne", "gt", "ge", "lt", "le", "contains":
+	default:
+		return nil, fmt.Errorf("unknown operator %q", op)
+	}
+
+	var matches []string
+	err := d.Scan(collection, func(name string, b []byte) (bool, error) {
+		raw, err := decodeField(bytes.NewReader(b), field)
+		if errors.Is(err, ErrFieldNotFound) {
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("unable to decode %v: %v", name, err)
+		}
+
+		ok, err := compareField(raw, op, value)
+		if err != nil {
+			return false, fmt.Errorf("unable to decode %v: %v", name, err)
+		}
+		if ok {
+			matches = append(matches, name)
+		}
+		return false, nil
+	})
+	return matches, err
+}
+
+func compareField(raw json.RawMessage, op, value string) (bool, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return false, err
+	}
+
+	switch v := v.(type) {
+	case json.Number:
+		x, ok := new(big.Rat).SetString(v.String())
+		y, ok2 := new(big.Rat).SetString(value)
+		if !ok || !ok2 || op == "contains" {
+			return false, nil
+		}
+		return ordered(x.Cmp(y), op), nil
+	case string:
+		if op == "contains" {
+			return strings.Contains(v, value), nil
+		}
+		return ordered(strings.Compare(v, value), op), nil
+	case []interface{}:
+		if op != "contains" {
+			return false, nil
+		}
+		for _, elem := range v {
+			b, _ := json.Marshal(elem)
+			if rawKey(b) == value {
+				return true, nil
+			}
+		}
+		return false, nil
+	case map[string]interface{}:
+		return false, nil
+	default:
+		s := strings.TrimSpace(string(raw))
+		switch op {
+		case "eq":
+			return s == value, nil
+		case "ne":
+			return s != value, nil
+		}
+		return false, nil
+	}
+}
+
+// ordered applies a comparison operator to the result of a Cmp-style
+// comparison.
+func ordered(c int, op string) bool {
+	switch op {
+	case "eq":
+		return c == 0
+	case "ne":
+		return c != 0
+	case "gt":
+		return c > 0
+	case "ge":
+		return c >= 0
+	case "lt":
+		return c < 0
+	case "le":
+		return c <= 0
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+//go:build linux
+
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	// O_TMPFILE is __O_TMPFILE | O_DIRECTORY. __O_TMPFILE is the same on
+	// every architecture Go supports, but O_DIRECTORY is not (0x10000 on
+	// amd64, 0x4000 on arm64), and syscall has no O_TMPFILE of its own.
+	oTmpfile        = 0x400000 | syscall.O_DIRECTORY
+	atFDCWD         = -0x64
+	atSymlinkFollow = 0x400
+)
+
+// writeFile atomically replaces path with b. On Linux the data is written to
+// an unnamed O_TMPFILE inode that only gets a name once it is complete, so
+// no partially written file is ever visible in the directory. A new record
+// is linked straight into place. An existing one is replaced by linking the
+// finished inode next to it as path.tmp and renaming that over path, since
+// rename needs a name to move: the complete new contents are briefly
+// visible under path.tmp, and a crash between the two steps leaves them
+// there for Options.StaleTmp to deal with. Filesystems without O_TMPFILE
+// support use writeFileRename.
+func (d *Driver) writeFile(path string, b []byte) error {
+	if d.opts.ReadOnly {
+		return ErrReadOnly
+	}
+	fd, err := syscall.Open(filepath.Dir(path), oTmpfile|syscall.O_WRONLY|syscall.O_CLOEXEC, uint32(d.fileMode.Perm()))
+	if err != nil {
+		return d.writeFileRename(path, b)
+	}
+
+	f := os.NewFile(uintptr(fd), path)
+	defer f.Close()
+
+	if _, err := f.Write(b); err != nil {
+		return err
+	}
+	if d.failAfterTempWrite != nil {
+		if err := d.failAfterTempWrite(path); err != nil {
+			return err
+		}
+	}
+
+	procPath := "/proc/self/fd/" + strconv.Itoa(fd)
+	err = linkat(procPath, path)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, syscall.EEXIST) {
+		return d.writeFileRename(path, b)
+	}
+
+	tmpPath := path + ".tmp"
+	os.Remove(tmpPath)
+	if err := linkat(procPath, tmpPath); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+func linkat(oldpath, newpath string) error {
+	oldp, err := syscall.BytePtrFromString(oldpath)
+	if err != nil {
+		return err
+	}
+	newp, err := syscall.BytePtrFromString(newpath)
+	if err != nil {
+		return err
+	}
+
+	dirfd := atFDCWD
+	_, _, errno := syscall.Syscall6(syscall.SYS_LINKAT,
+		uintptr(dirfd), uintptr(unsafe.Pointer(oldp)),
+		uintptr(dirfd), uintptr(unsafe.Pointer(newp)),
+		atSymlinkFollow, 0)
+	if errno != 0 {
+		return &os.LinkError{Op: "linkat", Old: oldpath, New: newpath, Err: errno}
+	}
+	return nil
+}
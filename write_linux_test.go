@@ -0,0 +1,21 @@
+package main
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+)
+
+// TestTmpfileFlag checks that oTmpfile is what this architecture's kernel
+// accepts, since a wrong value only shows up as writeFile silently falling
+// back to writeFileRename.
+func TestTmpfileFlag(t *testing.T) {
+	fd, err := syscall.Open(t.TempDir(), oTmpfile|syscall.O_WRONLY|syscall.O_CLOEXEC, 0644)
+	if errors.Is(err, syscall.EOPNOTSUPP) || errors.Is(err, syscall.EISDIR) {
+		t.Skipf("filesystem has no O_TMPFILE support: %v", err)
+	}
+	if err != nil {
+		t.Fatalf("open with oTmpfile: %v", err)
+	}
+	syscall.Close(fd)
+}
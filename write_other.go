@@ -0,0 +1,8 @@
+//go:build !linux
+
+package main
+
+// writeFile atomically replaces path with b.
+func (d *Driver) writeFile(path string, b []byte) error {
+	return d.writeFileRename(path, b)
+}